@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// -------------------- Administración --------------------
+
+// registroCorridas reúne las simulaciones en curso de todas las conexiones
+// (WebSocket, /api/stream y la API REST) por run_id, para poder cancelarlas desde afuera
+// de la sesión que las lanzó y consultar su estado (ver instantanea).
+type registroCorridas struct {
+	mu       sync.Mutex
+	corridas map[string]*corridaActiva
+}
+
+// corridaActiva es una simulación registrada: cómo detenerla y su estado,
+// que el runner actualiza con informarProgreso mientras corre
+type corridaActiva struct {
+	cancelar context.CancelFunc
+	mu       sync.Mutex // protege estado
+	estado   EstadoCorrida
+}
+
+// corridasActivas es el registro compartido por todas las conexiones
+var corridasActivas = &registroCorridas{corridas: map[string]*corridaActiva{}}
+
+// agregar registra la corrida estado.RunID, que se detiene con cancelar, y
+// devuelve el contexto en el que debe correr para informar su progreso y la
+// función que la quita del registro al terminar
+func (r *registroCorridas) agregar(ctx context.Context, estado EstadoCorrida, cancelar context.CancelFunc) (ctxCorrida context.Context, quitar func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := &corridaActiva{cancelar: cancelar, estado: estado}
+	r.corridas[estado.RunID] = c
+	return context.WithValue(ctx, claveCorridaActiva{}, c), func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.corridas, estado.RunID)
+	}
+}
+
+// cancelarTodas cancela las corridas registradas y devuelve sus run_id,
+// ordenados. Cada una termina como con "detener", con su finalizado.
+func (r *registroCorridas) cancelarTodas() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	canceladas := make([]string, 0, len(r.corridas))
+	for runID, c := range r.corridas {
+		c.cancelar()
+		canceladas = append(canceladas, runID)
+	}
+	sort.Strings(canceladas)
+	return canceladas
+}
+
+// instantanea devuelve el estado de las corridas registradas, de la más
+// antigua a la más reciente
+func (r *registroCorridas) instantanea() []EstadoCorrida {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	estados := make([]EstadoCorrida, 0, len(r.corridas))
+	for _, c := range r.corridas {
+		c.mu.Lock()
+		estado := c.estado
+		c.mu.Unlock()
+		estado.TranscurridoMs = time.Since(estado.Inicio).Milliseconds()
+		estados = append(estados, estado)
+	}
+	sort.Slice(estados, func(i, j int) bool {
+		if !estados[i].Inicio.Equal(estados[j].Inicio) {
+			return estados[i].Inicio.Before(estados[j].Inicio)
+		}
+		return estados[i].RunID < estados[j].RunID
+	})
+	return estados
+}
+
+// claveCorridaActiva guarda en el contexto de una simulación su corridaActiva
+type claveCorridaActiva struct{}
+
+// informarProgreso registra que la simulación que corre en ctx completó el
+// sector (0 si no tiene sectores) de la vuelta indicada. Lo llaman los runners
+// en cada paso, también desde varias goroutines: el progreso queda en el paso
+// más avanzado. Fuera del registro (benchmark) no hace nada.
+func informarProgreso(ctx context.Context, vuelta, sector int) {
+	c, ok := ctx.Value(claveCorridaActiva{}).(*corridaActiva)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := &c.estado.Progreso
+	p.Pasos++
+	if vuelta > p.Vuelta || vuelta == p.Vuelta && sector > p.Sector {
+		p.Vuelta, p.Sector = vuelta, sector
+	}
+}
+
+// cabeceraSecretoAdmin es la cabecera con la que se autentican los endpoints
+// de administración
+const cabeceraSecretoAdmin = "X-Admin-Secret"
+
+// secretoAdmin habilita los endpoints de administración; main lo toma del
+// flag -admin-secret (o la variable ADMIN_SECRET). Vacío = deshabilitados.
+var secretoAdmin = os.Getenv("ADMIN_SECRET")
+
+// autorizarAdmin responde el error y devuelve false si r no trae el secreto
+// de administración
+func autorizarAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if secretoAdmin == "" {
+		responderJSON(w, http.StatusForbidden, ErrorAPI{Error: "administración deshabilitada: iniciar el servidor con -admin-secret"})
+		return false
+	}
+	recibido := r.Header.Get(cabeceraSecretoAdmin)
+	if subtle.ConstantTimeCompare([]byte(recibido), []byte(secretoAdmin)) != 1 {
+		responderJSON(w, http.StatusUnauthorized, ErrorAPI{Error: "secreto de administración inválido (cabecera " + cabeceraSecretoAdmin + ")"})
+		return false
+	}
+	return true
+}
+
+// apiDetenerTodasHandler atiende POST /api/admin/stop-all: cancela todas las
+// simulaciones en curso, de todas las conexiones, y responde sus run_id
+func apiDetenerTodasHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar POST"})
+		return
+	}
+	if !autorizarAdmin(w, r) {
+		return
+	}
+	canceladas := corridasActivas.cancelarTodas()
+	slog.Warn("simulaciones detenidas por administración", "remoto", r.RemoteAddr, "run_ids", canceladas)
+	responderJSON(w, http.StatusOK, canceladas)
+}
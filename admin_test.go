@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetenerTodasCancelaLaAPIREST(t *testing.T) {
+	anteriorSecreto, anteriorConfig := secretoAdmin, configuracion
+	secretoAdmin = "prueba"
+	configuracion.MaxVueltas = 1_000_000
+	t.Cleanup(func() { secretoAdmin, configuracion = anteriorSecreto, anteriorConfig })
+	base := runtime.NumGoroutine()
+
+	// Sin pausas, un millón de vueltas no termina antes del stop-all
+	rest := httptest.NewRecorder()
+	hecho := make(chan struct{})
+	go func() {
+		defer close(hecho)
+		apiMPIHandler(rest, httptest.NewRequest(http.MethodPost, "/api/mpi", strings.NewReader(`{"sectores":5,"vueltas":1000000}`)))
+	}()
+	plazo := time.Now().Add(5 * time.Second)
+	var activas []EstadoCorrida
+	for len(activas) == 0 {
+		if time.Now().After(plazo) {
+			t.Fatal("la corrida de /api/mpi no apareció en el registro")
+		}
+		time.Sleep(time.Millisecond)
+		activas = corridasActivas.instantanea()
+	}
+	if len(activas) != 1 || activas[0].Topico != "mpi" || activas[0].Accion != "iniciar_mpi" {
+		t.Fatalf("corridas activas %+v, se esperaba solo la de /api/mpi", activas)
+	}
+
+	pedido := httptest.NewRequest(http.MethodPost, "/api/admin/stop-all", nil)
+	pedido.Header.Set(cabeceraSecretoAdmin, "prueba")
+	admin := httptest.NewRecorder()
+	apiDetenerTodasHandler(admin, pedido)
+	var canceladas []string
+	if err := json.NewDecoder(admin.Body).Decode(&canceladas); err != nil || admin.Code != http.StatusOK {
+		t.Fatalf("stop-all: código %d error %v", admin.Code, err)
+	}
+	if len(canceladas) != 1 || canceladas[0] != activas[0].RunID {
+		t.Errorf("stop-all canceló %v, se esperaba [%s]", canceladas, activas[0].RunID)
+	}
+
+	select {
+	case <-hecho:
+	case <-time.After(5 * time.Second):
+		t.Fatal("/api/mpi no terminó después del stop-all")
+	}
+	if rest.Code != http.StatusServiceUnavailable {
+		t.Errorf("/api/mpi detenida respondió %d, se esperaba 503", rest.Code)
+	}
+	esperarSinCorridas(t, base)
+}
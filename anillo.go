@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+Anillo de nodos (MPI)
+---------------------
+Cada nodo es una goroutine que recibe un token por el canal del nodo anterior
+y lo reenvía al siguiente, cerrando el anillo: el nodo i lee de canales[i-1]
+y el nodo 0 lee de canales[nodos-1]. El token (TokenAnillo) cuenta los saltos
+y las vueltas completas; el último nodo incrementa las vueltas y avisa cuando
+se alcanza el objetivo.
+
+Con la variante "coordinador" (mpiConCoordinador) el runner no queda solo
+mirando: es el nodo 0 del anillo, inyecta el token, lo recibe al final de
+cada vuelta y mide cuánto tardó la vuelta completa.
+*/
+
+// ParametrosAnillo agrupa la configuración de una simulación de anillo
+type ParametrosAnillo struct {
+	Nodos    int           `json:"nodos"`
+	Vueltas  int           `json:"vueltas_anillo"` // vueltas completas del token antes de terminar (0 = solo por tiempo)
+	Duracion time.Duration `json:"duracion_ns"`    // tiempo máximo que circula el token (resguardo)
+	Variante string        `json:"variante"`       // varianteClasica (o vacío) o varianteCoordinador
+	Retardo  time.Duration `json:"-"`              // pausa artificial de cada nodo antes de reenviar (0 = sin pausa)
+}
+
+// Variantes del anillo (parámetro variante)
+const (
+	varianteClasica     = "clasica"     // todos los nodos son goroutines; el runner espera
+	varianteCoordinador = "coordinador" // el runner es el nodo 0 (ver mpiConCoordinador)
+)
+
+// validar comprueba que los parámetros permitan correr el anillo
+func (p ParametrosAnillo) validar() error {
+	// Con un solo nodo el token volvería a su propio canal: no hay anillo
+	if p.Nodos < 2 {
+		return fmt.Errorf("nodos debe ser >= 2")
+	}
+	if p.Nodos > configuracion.MaxNodos {
+		return fmt.Errorf("nodos debe ser <= %d", configuracion.MaxNodos)
+	}
+	if p.Vueltas < 0 {
+		return fmt.Errorf("vueltas_anillo debe ser >= 0")
+	}
+	if p.Duracion <= 0 {
+		return fmt.Errorf("duracion_seg debe ser > 0")
+	}
+	if p.Retardo < 0 {
+		return fmt.Errorf("delay_ms debe ser >= 0")
+	}
+	if p.Variante != "" && p.Variante != varianteClasica && p.Variante != varianteCoordinador {
+		return fmt.Errorf("variante debe ser %s o %s", varianteClasica, varianteCoordinador)
+	}
+	return nil
+}
+
+// Circulan tokensAnillo tokens por canales con buffer capacidadCanalAnillo.
+// Un nodo que se retira deja su token en la salida sin esperar (ver
+// nodoAnillo), así que si los tokens no entran en un canal ese envío bloquea y
+// el anillo no termina nunca: comprobarCapacidadAnillo lo impide.
+const (
+	tokensAnillo         = 1
+	capacidadCanalAnillo = 1
+)
+
+// comprobarCapacidadAnillo rechaza inyectar más tokens de los que entran en
+// el buffer de un canal del anillo, porque podría bloquearse
+func comprobarCapacidadAnillo(tokens, capacidad int) error {
+	if tokens < 1 {
+		return fmt.Errorf("el anillo necesita al menos un token (hay %d)", tokens)
+	}
+	if tokens > capacidad {
+		return fmt.Errorf("%d tokens no entran en canales con buffer %d: el anillo podría bloquearse", tokens, capacidad)
+	}
+	return nil
+}
+
+// canalesAnillo crea los canales que unen los nodos, uno por nodo
+func canalesAnillo(nodos int) []chan TokenAnillo {
+	canales := make([]chan TokenAnillo, nodos)
+	for i := range canales {
+		canales[i] = make(chan TokenAnillo, capacidadCanalAnillo)
+	}
+	return canales
+}
+
+// retardoSalto es la pausa artificial por defecto de cada nodo antes de
+// reenviar el token (ver ParametrosAnillo.Retardo)
+const retardoSalto = 1 * time.Second
+
+// TokenAnillo es el mensaje que circula entre los nodos
+type TokenAnillo struct {
+	Origen   int       // nodo que recibió el token primero
+	Saltos   int       // reenvíos entre nodos hasta el momento
+	Vueltas  int       // vueltas completas al anillo
+	Iniciado time.Time // momento en que se inyectó el token
+
+	// Latencia medida de los saltos: desde que un nodo recibe el token hasta
+	// que lo reenvía (incluye la pausa artificial)
+	LatenciaTotal time.Duration
+	LatenciaMin   time.Duration
+	LatenciaMax   time.Duration
+}
+
+// registrarSalto cuenta el salto del nodo que recibió el token en recibido y
+// está por reenviarlo
+func (t *TokenAnillo) registrarSalto(recibido time.Time) {
+	latencia := time.Since(recibido)
+	t.Saltos++
+	t.LatenciaTotal += latencia
+	if t.Saltos == 1 || latencia < t.LatenciaMin {
+		t.LatenciaMin = latencia
+	}
+	t.LatenciaMax = max(t.LatenciaMax, latencia)
+}
+
+// nodoAnillo recibe el token de entrada, informa el salto por enviar (nunca
+// por la salida estándar, así llega al cliente que corre el anillo) y lo
+// reenvía a salida hasta que se cancele ctx. La espera del token y la pausa
+// observan ctx; el reenvío a salida no lo necesita porque nunca bloquea: los
+// tokens entran en el buffer de los canales (ver comprobarCapacidadAnillo).
+// Los mensajes por enviar sí esperan a que los lea el runner.
+// wg pertenece a la corrida que creó el nodo (no hay estado global), así que
+// varios clientes pueden correr anillos simultáneos sin pisarse los contadores.
+//
+// Solo el último nodo recibe completo (los demás reciben nil): al pasar el
+// token por él se completa una vuelta, y si se llegó a objetivo entrega el
+// token por completo (con buffer de uno) en lugar de reenviarlo y se retira,
+// así no se cuentan saltos de más mientras se detienen los otros nodos.
+//
+// Si se cancela ctx, el nodo que tiene el token lo deja en salida para que el
+// runner lo recupere y arme el resumen.
+func nodoAnillo(ctx context.Context, wg *sync.WaitGroup, id int, entrada <-chan TokenAnillo, salida chan<- TokenAnillo, enviar chan MensajeWS, retardo time.Duration, objetivo int, completo chan<- TokenAnillo) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case token := <-entrada:
+			enviar <- MensajeWS{Tipo: "registro", Topico: "anillo", Texto: fmt.Sprintf("Ping desde nodo %d", id)}
+			// El salto se mide sin los mensajes al cliente, que esperan a
+			// que los lea: solo la pausa y el planificador hasta reenviar
+			recibido := time.Now()
+			if !esperar(ctx, retardo) {
+				salida <- token
+				return
+			}
+			token.registrarSalto(recibido)
+			if completo != nil {
+				token.Vueltas++
+				enviar <- MensajeWS{Tipo: "registro", Topico: "anillo", Texto: fmt.Sprintf("Vuelta %d del anillo completa", token.Vueltas)}
+				informarProgreso(ctx, token.Vueltas, 0)
+			}
+			if completo != nil && objetivo > 0 && token.Vueltas >= objetivo {
+				completo <- token
+				return
+			}
+			salida <- token
+		}
+	}
+}
+
+// correrAnillo arma el anillo de nodos, inyecta el token y lo deja circular
+// hasta completar p.Vueltas vueltas. p.Duracion actúa como tope de tiempo y
+// también corta si se cancela ctx.
+func correrAnillo(ctx context.Context, p ParametrosAnillo, enviar chan MensajeWS) {
+	if err := p.validar(); err != nil {
+		enviar <- mensajeError("anillo", err)
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "anillo"}
+		return
+	}
+	if err := comprobarCapacidadAnillo(tokensAnillo, capacidadCanalAnillo); err != nil {
+		enviar <- mensajeError("anillo", err)
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "anillo"}
+		return
+	}
+	if p.Variante == "" {
+		p.Variante = varianteClasica
+	}
+
+	enviar <- MensajeWS{
+		Tipo:   "registro",
+		Topico: "anillo",
+		Texto:  fmt.Sprintf("Iniciando anillo (%s): %d nodos, %d vueltas, tope %s", p.Variante, p.Nodos, p.Vueltas, p.Duracion),
+	}
+
+	ctxAnillo, cancelar := context.WithTimeout(ctx, p.Duracion)
+	defer cancelar()
+
+	var token TokenAnillo
+	var latencias []float64
+	var completo bool
+	if p.Variante == varianteCoordinador {
+		token, latencias, completo = mpiConCoordinador(ctxAnillo, p, enviar)
+	} else {
+		token, completo = anilloClasico(ctxAnillo, p, enviar)
+	}
+
+	texto := "Anillo detenido por tiempo máximo"
+	switch {
+	case completo:
+		texto = fmt.Sprintf("Anillo finalizado: %d vueltas completas", p.Vueltas)
+	case ctx.Err() != nil:
+		texto = "Anillo detenido"
+	}
+
+	resumen := ResumenAnillo{Nodos: p.Nodos, Vueltas: token.Vueltas, Saltos: token.Saltos, Variante: p.Variante, LatenciasVueltaMs: latencias}
+	if token.Saltos > 0 {
+		ms := func(d time.Duration) float64 { return redondear(float64(d) / float64(time.Millisecond)) }
+		resumen.LatenciaMediaMs = ms(token.LatenciaTotal / time.Duration(token.Saltos))
+		resumen.LatenciaMinMs = ms(token.LatenciaMin)
+		resumen.LatenciaMaxMs = ms(token.LatenciaMax)
+	}
+	enviar <- MensajeWS{
+		Tipo:   "resumen",
+		Topico: "anillo",
+		Texto: fmt.Sprintf("Resultados anillo: %d saltos en %d vueltas, %.2f ms por salto (mín %.2f, máx %.2f)",
+			resumen.Saltos, resumen.Vueltas, resumen.LatenciaMediaMs, resumen.LatenciaMinMs, resumen.LatenciaMaxMs),
+		Obj: resumen,
+	}
+	enviar <- MensajeWS{Tipo: "finalizado", Topico: "anillo", Texto: texto}
+}
+
+// anilloClasico corre todos los nodos como goroutines y espera a que el
+// último entregue el token con p.Vueltas vueltas o a que se cancele ctx.
+// Devuelve el token final y si se llegó al objetivo.
+func anilloClasico(ctx context.Context, p ParametrosAnillo, enviar chan MensajeWS) (TokenAnillo, bool) {
+	ctxNodos, pararNodos := context.WithCancel(ctx)
+	defer pararNodos()
+
+	canales := canalesAnillo(p.Nodos)
+	completo := make(chan TokenAnillo, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < p.Nodos; i++ {
+		wg.Add(1)
+		entrada := canales[(i-1+p.Nodos)%p.Nodos]
+		var aviso chan<- TokenAnillo
+		if i == p.Nodos-1 {
+			aviso = completo
+		}
+		go nodoAnillo(ctxNodos, &wg, i, entrada, canales[i], enviar, p.Retardo, p.Vueltas, aviso)
+	}
+
+	// El token entra por el canal del último nodo para que el nodo 0 lo reciba primero
+	canales[p.Nodos-1] <- TokenAnillo{Origen: 0, Iniciado: time.Now()}
+
+	var token TokenAnillo
+	llego := false
+	select {
+	case token = <-completo:
+		llego = true
+	case <-ctx.Done():
+	}
+
+	// Espera a que todos los nodos salgan antes de informar el fin, así ningún
+	// "Ping" llega después del finalizado ni queda una goroutine colgada.
+	pararNodos()
+	wg.Wait()
+	if !llego {
+		// El último nodo pudo llegar al objetivo justo cuando se canceló ctx
+		select {
+		case token = <-completo:
+			llego = true
+		default:
+			token = recuperarToken(canales)
+		}
+	}
+	return token, llego
+}
+
+// mpiConCoordinador corre el anillo con el runner como nodo 0: los nodos 1 a
+// p.Nodos-1 son goroutines y el runner inyecta el token, hace su propio salto
+// y lo espera de vuelta. Cada vez que el token regresa informa la latencia de
+// la vuelta completa. Devuelve el token final, esas latencias y si se llegó a
+// p.Vueltas.
+func mpiConCoordinador(ctx context.Context, p ParametrosAnillo, enviar chan MensajeWS) (TokenAnillo, []float64, bool) {
+	ctxNodos, pararNodos := context.WithCancel(ctx)
+	defer pararNodos()
+
+	// canales[i] va del nodo i al i+1; el último vuelve al nodo 0
+	canales := canalesAnillo(p.Nodos)
+	var wg sync.WaitGroup
+	for i := 1; i < p.Nodos; i++ {
+		wg.Add(1)
+		go nodoAnillo(ctxNodos, &wg, i, canales[i-1], canales[i], enviar, p.Retardo, p.Vueltas, nil)
+	}
+	terminar := func(token TokenAnillo, enMano bool) TokenAnillo {
+		pararNodos()
+		wg.Wait()
+		if enMano {
+			return token
+		}
+		return recuperarToken(canales)
+	}
+
+	token := TokenAnillo{Origen: 0, Iniciado: time.Now()}
+	var latencias []float64
+	for {
+		inicioVuelta := time.Now()
+		enviar <- MensajeWS{Tipo: "registro", Topico: "anillo", Texto: "Ping desde nodo 0 (coordinador)"}
+		recibido := time.Now() // el salto propio se mide como en nodoAnillo
+		if !esperar(ctx, p.Retardo) {
+			return terminar(token, true), latencias, false
+		}
+		token.registrarSalto(recibido)
+		canales[0] <- token
+
+		select {
+		case token = <-canales[p.Nodos-1]:
+		case <-ctx.Done():
+			return terminar(token, false), latencias, false
+		}
+		token.Vueltas++
+		informarProgreso(ctx, token.Vueltas, 0)
+		latencia := redondear(float64(time.Since(inicioVuelta)) / float64(time.Millisecond))
+		latencias = append(latencias, latencia)
+		enviar <- MensajeWS{
+			Tipo:   "registro",
+			Topico: "anillo",
+			Texto:  fmt.Sprintf("Vuelta %d del anillo completa en %.2f ms", token.Vueltas, latencia),
+			Obj:    LatenciaVuelta{Vuelta: token.Vueltas, LatenciaMs: latencia},
+		}
+		if p.Vueltas > 0 && token.Vueltas >= p.Vueltas {
+			return terminar(token, true), latencias, true
+		}
+	}
+}
+
+// recuperarToken busca el token en los canales una vez detenidos todos los
+// nodos: el que lo tenía lo dejó en su salida al retirarse
+func recuperarToken(canales []chan TokenAnillo) TokenAnillo {
+	var token TokenAnillo
+	for _, c := range canales {
+		select {
+		case token = <-c:
+		default:
+		}
+	}
+	return token
+}
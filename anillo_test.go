@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// parametrosAnilloPrueba son parámetros de anillo sin pausas entre saltos
+func parametrosAnilloPrueba(nodos, vueltas int, variante string) ParametrosAnillo {
+	return ParametrosAnillo{Nodos: nodos, Vueltas: vueltas, Duracion: 5 * time.Second, Variante: variante}
+}
+
+// pingsAnillo devuelve, en orden, los nodos que informaron haber recibido el token
+func pingsAnillo(mensajes []MensajeWS) []int {
+	var nodos []int
+	for _, msg := range mensajes {
+		var nodo int
+		if _, err := fmt.Sscanf(msg.Texto, "Ping desde nodo %d", &nodo); err == nil {
+			nodos = append(nodos, nodo)
+		}
+	}
+	return nodos
+}
+
+// comprobarVueltasCompletas verifica que cada vuelta el token pasó por los
+// nodos en orden, de 0 a nodos-1, y que no dio saltos después de la última
+func comprobarVueltasCompletas(t *testing.T, pings []int, nodos, vueltas int) {
+	t.Helper()
+	if len(pings) != nodos*vueltas {
+		t.Fatalf("%d pings, se esperaban %d: %v", len(pings), nodos*vueltas, pings)
+	}
+	for i, nodo := range pings {
+		if nodo != i%nodos {
+			t.Fatalf("en la vuelta %d el token pasó por el nodo %d en lugar del %d: %v", i/nodos+1, nodo, i%nodos, pings)
+		}
+	}
+}
+
+func TestAnilloAvanzaHastaElObjetivo(t *testing.T) {
+	const nodos, vueltas = 5, 4
+	variantes := map[string]func(ctx context.Context, p ParametrosAnillo, enviar chan MensajeWS) (TokenAnillo, bool){
+		varianteClasica: anilloClasico,
+		varianteCoordinador: func(ctx context.Context, p ParametrosAnillo, enviar chan MensajeWS) (TokenAnillo, bool) {
+			token, _, completo := mpiConCoordinador(ctx, p, enviar)
+			return token, completo
+		},
+	}
+	for variante, correr := range variantes {
+		ctx, cancelar := context.WithTimeout(context.Background(), 5*time.Second)
+		var token TokenAnillo
+		var completo bool
+		var mensajes []MensajeWS
+		recorrerMensajes(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+			token, completo = correr(ctx, parametrosAnilloPrueba(nodos, vueltas, variante), enviar)
+		}, func(msg MensajeWS) {
+			mensajes = append(mensajes, msg)
+		})
+		cancelar()
+		if !completo {
+			t.Fatalf("%s: el token no completó %d vueltas antes del plazo (llegó a %d)", variante, vueltas, token.Vueltas)
+		}
+		if token.Vueltas != vueltas || token.Saltos != nodos*vueltas {
+			t.Errorf("%s: token con %d vueltas y %d saltos, se esperaban %d y %d", variante, token.Vueltas, token.Saltos, vueltas, nodos*vueltas)
+		}
+		comprobarVueltasCompletas(t, pingsAnillo(mensajes), nodos, vueltas)
+	}
+}
+
+func TestComprobarCapacidadAnillo(t *testing.T) {
+	if err := comprobarCapacidadAnillo(tokensAnillo, capacidadCanalAnillo); err != nil {
+		t.Fatalf("la configuración del anillo no pasa su propia comprobación: %v", err)
+	}
+	casos := []struct {
+		tokens, capacidad int
+		valido            bool
+	}{
+		{1, 1, true},
+		{2, 2, true},
+		{2, 1, false},
+		{5, 4, false},
+		{0, 1, false},
+	}
+	for _, c := range casos {
+		if err := comprobarCapacidadAnillo(c.tokens, c.capacidad); (err == nil) != c.valido {
+			t.Errorf("comprobarCapacidadAnillo(%d, %d) = %v, válido esperado %v", c.tokens, c.capacidad, err, c.valido)
+		}
+	}
+}
+
+// esperarGoroutines espera a que vuelva a haber como mucho base goroutines
+func esperarGoroutines(t *testing.T, base int) {
+	t.Helper()
+	plazo := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(plazo) {
+			t.Fatalf("quedaron %d goroutines, había %d antes de empezar", runtime.NumGoroutine(), base)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAnilloNoDejaGoroutines(t *testing.T) {
+	base := runtime.NumGoroutine()
+	for _, variante := range []string{varianteClasica, varianteCoordinador} {
+		// Hasta el objetivo, por el tope de tiempo y detenido a mitad de camino
+		completo := parametrosAnilloPrueba(8, 3, variante)
+		porTiempo := parametrosAnilloPrueba(8, 0, variante)
+		porTiempo.Duracion, porTiempo.Retardo = 30*time.Millisecond, time.Millisecond
+		detenido := parametrosAnilloPrueba(8, 0, variante)
+		detenido.Retardo = 5 * time.Millisecond
+		for _, p := range []ParametrosAnillo{completo, porTiempo, detenido} {
+			ctx, cancelar := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			mensajes := 0
+			recorrerMensajes(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+				correrAnillo(ctx, p, enviar)
+			}, func(MensajeWS) { mensajes++ })
+			cancelar()
+			if mensajes == 0 {
+				t.Fatalf("%s: el anillo no envió mensajes", variante)
+			}
+		}
+	}
+	esperarGoroutines(t, base)
+}
+
+func TestTokenVisitaTodosLosNodos(t *testing.T) {
+	const vueltas = 2
+	for _, variante := range []string{varianteClasica, varianteCoordinador} {
+		for _, nodos := range []int{2, 7, configuracion.MaxNodos} {
+			mensajes := correrHastaElFinal(func(ctx context.Context, enviar chan MensajeWS) {
+				correrAnillo(ctx, parametrosAnilloPrueba(nodos, vueltas, variante), enviar)
+			})
+			comprobarVueltasCompletas(t, pingsAnillo(mensajes), nodos, vueltas)
+			for _, msg := range mensajes {
+				if r, ok := msg.Obj.(ResumenAnillo); ok && (r.Saltos != nodos*vueltas || r.Vueltas != vueltas) {
+					t.Errorf("%s con %d nodos: resumen con %d saltos y %d vueltas, se esperaban %d y %d", variante, nodos, r.Saltos, r.Vueltas, nodos*vueltas, vueltas)
+				}
+			}
+		}
+		for _, nodos := range []int{1, configuracion.MaxNodos + 1} {
+			mensajes := correrHastaElFinal(func(ctx context.Context, enviar chan MensajeWS) {
+				correrAnillo(ctx, parametrosAnilloPrueba(nodos, vueltas, variante), enviar)
+			})
+			comprobarErrorYFinalizado(t, mensajes, "anillo")
+		}
+	}
+}
+
+func TestLatenciaAnilloIncluyeElRetardo(t *testing.T) {
+	const retardo = 5 * time.Millisecond
+	for _, variante := range []string{varianteClasica, varianteCoordinador} {
+		p := parametrosAnilloPrueba(3, 2, variante)
+		p.Retardo = retardo
+		resumen, ok := ejecutarSincronico(context.Background(), func(ctx context.Context, enviar chan MensajeWS) {
+			correrAnillo(ctx, p, enviar)
+		}).(ResumenAnillo)
+		if !ok {
+			t.Fatalf("%s: el anillo no envió un resumen", variante)
+		}
+		minimo := float64(retardo) / float64(time.Millisecond)
+		if resumen.LatenciaMinMs < minimo || resumen.LatenciaMediaMs < resumen.LatenciaMinMs || resumen.LatenciaMaxMs < resumen.LatenciaMediaMs {
+			t.Errorf("%s: latencias mín %.2f, media %.2f, máx %.2f ms con un retardo de %.0f ms", variante, resumen.LatenciaMinMs, resumen.LatenciaMediaMs, resumen.LatenciaMaxMs, minimo)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -------------------- API REST (ejecución sincrónica) --------------------
+
+// ErrorAPI es el cuerpo de las respuestas de error de la API
+type ErrorAPI struct {
+	Error string `json:"error"`
+}
+
+// responderJSON escribe v como JSON con el código de estado indicado
+func responderJSON(w http.ResponseWriter, estado int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(estado)
+	json.NewEncoder(w).Encode(v)
+}
+
+// recorrerMensajes corre la simulación hasta el final y llama a f con cada
+// mensaje, en orden. Con Retardo 0 y una Semilla fija la secuencia es
+// determinista, lo que permite inspeccionar la salida completa de un runner.
+func recorrerMensajes(ctx context.Context, correr func(ctx context.Context, enviar chan MensajeWS), f func(MensajeWS)) {
+	enviar := make(chan MensajeWS, 100)
+	go func() {
+		defer close(enviar)
+		correr(ctx, enviar)
+	}()
+	for msg := range enviar {
+		f(msg)
+	}
+}
+
+// ejecutarSincronico corre la simulación hasta el final descartando los
+// registros intermedios y devuelve el contenido del mensaje "resumen".
+func ejecutarSincronico(ctx context.Context, correr func(ctx context.Context, enviar chan MensajeWS)) any {
+	var resumen any
+	recorrerMensajes(ctx, correr, func(msg MensajeWS) {
+		if msg.Tipo == "resumen" {
+			resumen = msg.Obj
+		}
+	})
+	return resumen
+}
+
+// responderCorrida corre la simulación tipo para una petición REST y responde
+// su resumen. Como por WebSocket y /api/stream, la corrida queda registrada
+// en corridasActivas (la detiene /api/admin/stop-all) y se aborta a las
+// duracionMaxima; si termina sin resumen se responde 503.
+func responderCorrida(w http.ResponseWriter, r *http.Request, tipo string, parametros any, correr func(ctx context.Context, enviar chan MensajeWS)) {
+	ctx, cancelar := context.WithCancel(r.Context())
+	defer cancelar()
+	ctx, quitar := corridasActivas.agregar(ctx, EstadoCorrida{RunID: nuevoRunID(), Topico: tipo, Accion: "iniciar_" + tipo, Parametros: parametros, Inicio: time.Now()}, cancelar)
+	defer quitar()
+	defer medirCorrida(tipo)()
+	resumen := ejecutarSincronico(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+		conTiempoMaximo(ctx, enviar, correr)
+	})
+	if resumen == nil {
+		responderJSON(w, http.StatusServiceUnavailable, ErrorAPI{Error: "la simulación se detuvo antes de terminar"})
+		return
+	}
+	responderJSON(w, http.StatusOK, resumen)
+}
+
+// comandoDesdeCuerpo lee el cuerpo JSON de una petición REST como un Comando
+// con la acción indicada, con los mismos límites y validaciones que un
+// comando recibido por WebSocket (un 5.7 en un campo entero es un error)
+func comandoDesdeCuerpo(r *http.Request, accion string) (Comando, error) {
+	datos, err := io.ReadAll(io.LimitReader(r.Body, maxTamanioComando+1))
+	if err != nil {
+		return Comando{}, err
+	}
+	if len(datos) > maxTamanioComando {
+		return Comando{}, errComandoGrande
+	}
+	comando, err := decodificarComando(datos)
+	comando.Action = accion
+	return comando, err
+}
+
+// apiMPIHandler atiende POST /api/mpi con un cuerpo {"sectores":5,"vueltas":3}
+func apiMPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar POST"})
+		return
+	}
+	comando, err := comandoDesdeCuerpo(r, "iniciar_mpi")
+	if err != nil {
+		responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+		return
+	}
+	p := comando.parametrosMPI()
+	if err := p.validar(); err != nil {
+		responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+		return
+	}
+	p.Retardo = 0
+
+	responderCorrida(w, r, "mpi", p, func(ctx context.Context, enviar chan MensajeWS) {
+		correrMPI(ctx, p, nil, enviar)
+	})
+}
+
+// apiOpenMPHandler atiende POST /api/openmp con un cuerpo {"autos":4,"vueltas":5}
+func apiOpenMPHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar POST"})
+		return
+	}
+	comando, err := comandoDesdeCuerpo(r, "iniciar_openmp")
+	if err != nil {
+		responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+		return
+	}
+	p := comando.parametrosOpenMP()
+	if err := p.validar(); err != nil {
+		responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+		return
+	}
+	p.Retardo = 0
+
+	responderCorrida(w, r, "openmp", p, func(ctx context.Context, enviar chan MensajeWS) {
+		correrOpenMP(ctx, p, nil, enviar)
+	})
+}
+
+// apiStreamHandler atiende GET /api/stream/mpi y /api/stream/openmp con los
+// parámetros en la URL (?autos=4&vueltas=5). Corre la simulación en tiempo
+// real y escribe cada MensajeWS como una línea JSON apenas se genera, para
+// clientes que no pueden usar WebSocket. Si el cliente corta, se cancela.
+func apiStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar GET"})
+		return
+	}
+	vaciador, ok := w.(http.Flusher)
+	if !ok {
+		responderJSON(w, http.StatusInternalServerError, ErrorAPI{Error: "la conexión no permite streaming"})
+		return
+	}
+	comando, err := comandoDesdeQuery(r.URL.Query())
+	if err != nil {
+		responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+		return
+	}
+	tipo := strings.TrimPrefix(r.URL.Path, "/api/stream/")
+	comando.Action = "iniciar_" + tipo
+	comando.fijarSemilla()
+	var parametros any
+	var correr func(ctx context.Context, enviar chan MensajeWS)
+	switch tipo {
+	case "mpi":
+		p := comando.parametrosMPI()
+		if err := p.validar(); err != nil {
+			responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+			return
+		}
+		parametros = p
+		correr = func(ctx context.Context, enviar chan MensajeWS) { correrMPI(ctx, p, nil, enviar) }
+	case "openmp":
+		p := comando.parametrosOpenMP()
+		if err := p.validar(); err != nil {
+			responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+			return
+		}
+		parametros = p
+		correr = func(ctx context.Context, enviar chan MensajeWS) { correrOpenMP(ctx, p, nil, enviar) }
+	default:
+		responderJSON(w, http.StatusNotFound, ErrorAPI{Error: fmt.Sprintf("simulación no reconocida: %q (usar mpi u openmp)", tipo)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	vaciador.Flush()
+	// Igual que por WebSocket: run_id, ms_epoch, grabación para reproducir e historial
+	runID := nuevoRunID()
+	inicio := time.Now()
+	ctx, cancelar := context.WithCancel(r.Context())
+	defer cancelar()
+	ctx, quitar := corridasActivas.agregar(ctx, EstadoCorrida{RunID: runID, Topico: tipo, Accion: comando.Action, Parametros: parametros, Inicio: inicio}, cancelar)
+	defer quitar()
+	defer medirCorrida(tipo)()
+	codificador := json.NewEncoder(w)
+	recorrerMensajes(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+		salida, listo := etiquetarCorrida(runID, enviar)
+		conTiempoMaximo(ctx, salida, correr)
+		close(salida)
+		if err := registrarEnHistorial(runID, comando.Action, comando.Semilla, parametros, inicio, <-listo); err != nil {
+			slog.Warn("no se pudo guardar la corrida en el historial", "run_id", runID, "error", err)
+		}
+	}, func(msg MensajeWS) {
+		codificador.Encode(msg)
+		vaciador.Flush()
+	})
+}
+
+// apiUltimoOpenMPCSVHandler atiende GET /api/openmp/ultimo.csv con la última corrida OpenMP completada
+func apiUltimoOpenMPCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar GET"})
+		return
+	}
+	resumen := obtenerUltimoOpenMP()
+	if resumen == nil {
+		responderJSON(w, http.StatusNotFound, ErrorAPI{Error: "todavía no se completó ninguna corrida OpenMP"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="openmp_ultimo.csv"`)
+	escritor := csv.NewWriter(w)
+	escritor.Write([]string{"auto_id", "mejor_vuelta", "cantidad_vueltas", "promedio_vuelta"})
+	for _, auto := range resumen.MejorPorAuto {
+		escritor.Write([]string{
+			strconv.Itoa(auto.AutoID),
+			strconv.FormatFloat(auto.MejorVuelta, 'f', 2, 64),
+			strconv.Itoa(auto.CantidadVueltas),
+			strconv.FormatFloat(auto.PromedioVuelta, 'f', 2, 64),
+		})
+	}
+	escritor.Flush()
+}
+
+// apiComandosHandler atiende GET /api/comandos con la descripción de los
+// comandos WebSocket, sus parámetros, valores por defecto y límites
+func apiComandosHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar GET"})
+		return
+	}
+	responderJSON(w, http.StatusOK, describirComandos())
+}
+
+// EstimacionDuracion es la respuesta de GET /api/estimar
+type EstimacionDuracion struct {
+	Tipo       string `json:"tipo"`
+	Pasos      int    `json:"pasos"` // esperas que se hacen una detrás de otra
+	DuracionMs int64  `json:"duracion_ms"`
+	Duracion   string `json:"duracion"`
+}
+
+// comandoDesdeQuery arma un Comando con los parámetros de la URL (salvo
+// tipo), para validarlos igual que los comandos WebSocket. Los valores
+// numéricos y true/false se toman como tales; el resto como texto. Los
+// enteros se leen aparte para no perder dígitos de semillas largas.
+func comandoDesdeQuery(q url.Values) (Comando, error) {
+	campos := map[string]any{}
+	for clave, valores := range q {
+		if clave == "tipo" || len(valores) == 0 {
+			continue
+		}
+		valor := valores[0]
+		if n, err := strconv.ParseInt(valor, 10, 64); err == nil {
+			campos[clave] = n
+		} else if n, err := strconv.ParseFloat(valor, 64); err == nil {
+			campos[clave] = n
+		} else if b, err := strconv.ParseBool(valor); err == nil {
+			campos[clave] = b
+		} else {
+			campos[clave] = valor
+		}
+	}
+	datos, _ := json.Marshal(campos)
+	return decodificarComando(datos)
+}
+
+// estimarDuracion calcula cuánto tardaría en correr la simulación tipo con
+// los parámetros de c, sumando las esperas de cada paso (sin pausas).
+func estimarDuracion(tipo string, c Comando) (EstimacionDuracion, error) {
+	var pasos int
+	var retardo time.Duration
+	switch tipo {
+	case "mpi":
+		p := c.parametrosMPI().conCircuito()
+		if err := p.validar(); err != nil {
+			return EstimacionDuracion{}, err
+		}
+		// Los sectores se recorren en orden, vuelta tras vuelta; en pipeline
+		// las vueltas se solapan y se ahorra el llenado de cada etapa
+		pasos, retardo = p.Sectores*p.Vueltas, p.Retardo
+		if p.Pipeline {
+			pasos = p.Sectores + p.Vueltas - 1
+		}
+	case "openmp":
+		p := c.parametrosOpenMP()
+		if err := p.validar(); err != nil {
+			return EstimacionDuracion{}, err
+		}
+		// Los autos corren en paralelo; con menos hilos que autos corren por
+		// tandas, porque cada auto ocupa su hilo la carrera entera
+		hilos := p.Hilos
+		if hilos == 0 || hilos > p.Autos {
+			hilos = p.Autos
+		}
+		tandas := (p.Autos + hilos - 1) / hilos
+		pasos, retardo = tandas*p.Vueltas, p.Retardo
+	case "clasificacion":
+		p := c.parametrosOpenMP()
+		p.Vueltas = 1 // como en correrClasificacion
+		if err := p.validar(); err != nil {
+			return EstimacionDuracion{}, err
+		}
+		pasos, retardo = 1, p.Retardo
+	case "anillo":
+		p := c.parametrosAnillo()
+		if err := p.validar(); err != nil {
+			return EstimacionDuracion{}, err
+		}
+		// El último nodo corta al completar las vueltas; sin vueltas objetivo
+		// (o si no llegan a completarse) corta el tope de tiempo
+		pasos, retardo = p.Nodos*p.Vueltas, p.Retardo
+		if p.Vueltas == 0 || time.Duration(pasos)*p.Retardo > p.Duracion {
+			// Sin pausa los saltos que entran en el tope no se pueden estimar
+			pasos = 0
+			if p.Retardo > 0 {
+				pasos = int(p.Duracion / p.Retardo)
+			}
+			return EstimacionDuracion{Tipo: tipo, Pasos: pasos, DuracionMs: p.Duracion.Milliseconds(), Duracion: p.Duracion.String()}, nil
+		}
+	default:
+		return EstimacionDuracion{}, fmt.Errorf("tipo no reconocido: %q (usar mpi, openmp, clasificacion o anillo)", tipo)
+	}
+	duracion := time.Duration(pasos) * retardo
+	return EstimacionDuracion{Tipo: tipo, Pasos: pasos, DuracionMs: duracion.Milliseconds(), Duracion: duracion.String()}, nil
+}
+
+// apiEstimarHandler atiende GET /api/estimar?tipo=mpi&sectores=5&vueltas=3&delay_ms=300
+// sin correr la simulación
+func apiEstimarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar GET"})
+		return
+	}
+	comando, err := comandoDesdeQuery(r.URL.Query())
+	if err != nil {
+		responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+		return
+	}
+	estimacion, err := estimarDuracion(r.URL.Query().Get("tipo"), comando)
+	if err != nil {
+		responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: err.Error()})
+		return
+	}
+	responderJSON(w, http.StatusOK, estimacion)
+}
+
+// inicioServidor es el momento en que arrancó el servidor (se fija en main)
+var inicioServidor time.Time
+
+// EstadoSalud es la respuesta de GET /healthz
+type EstadoSalud struct {
+	Status     string `json:"status"`
+	Goroutines int    `json:"goroutines"`
+	Uptime     string `json:"uptime"`
+}
+
+// healthzHandler atiende GET /healthz para balanceadores y orquestadores de contenedores
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	responderJSON(w, http.StatusOK, EstadoSalud{
+		Status:     "ok",
+		Goroutines: runtime.NumGoroutine(),
+		Uptime:     time.Since(inicioServidor).Round(time.Second).String(),
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestComandoDesdeQueryConservaSemillasLargas(t *testing.T) {
+	const semilla = int64(1760418000123456789)
+	c, err := comandoDesdeQuery(url.Values{"semilla": {"1760418000123456789"}, "vueltas": {"3"}, "sectores": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Semilla == nil || *c.Semilla != semilla {
+		t.Fatalf("semilla %v, se esperaba %d", c.Semilla, semilla)
+	}
+	c.Action = "iniciar_mpi"
+	p := c.parametrosMPI()
+	if p.Semilla == nil || *p.Semilla != semilla {
+		t.Fatalf("semilla en los parámetros %v, se esperaba %d", p.Semilla, semilla)
+	}
+	if p.Vueltas != 3 || p.Sectores != 2 {
+		t.Fatalf("vueltas %d sectores %d, se esperaban 3 y 2", p.Vueltas, p.Sectores)
+	}
+}
+
+// postAPI llama a handler con un POST de cuerpo y devuelve el código y el error informado
+func postAPI(t *testing.T, handler http.HandlerFunc, ruta, cuerpo string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, ruta, strings.NewReader(cuerpo)))
+	var respuesta ErrorAPI
+	if err := json.NewDecoder(rec.Body).Decode(&respuesta); err != nil {
+		t.Fatalf("%s %s: respuesta no es JSON: %v", ruta, cuerpo, err)
+	}
+	return rec.Code, respuesta.Error
+}
+
+func TestAPIValidaEnterosComoWebSocket(t *testing.T) {
+	casos := []struct {
+		handler http.HandlerFunc
+		ruta    string
+		campo   string
+		base    string
+	}{
+		{apiMPIHandler, "/api/mpi", "sectores", `"vueltas":1`},
+		{apiOpenMPHandler, "/api/openmp", "autos", `"vueltas":1,"hilos":2`},
+	}
+	for _, c := range casos {
+		if codigo, msg := postAPI(t, c.handler, c.ruta, `{`+c.base+`,"`+c.campo+`":5.0}`); codigo != http.StatusOK {
+			t.Errorf("%s %s 5.0: código %d (%s), se esperaba 200", c.ruta, c.campo, codigo, msg)
+		}
+		codigo, msg := postAPI(t, c.handler, c.ruta, `{`+c.base+`,"`+c.campo+`":5.7}`)
+		if codigo != http.StatusBadRequest || msg != c.campo+" debe ser entero" {
+			t.Errorf("%s %s 5.7: código %d error %q, se esperaba 400 %q", c.ruta, c.campo, codigo, msg, c.campo+" debe ser entero")
+		}
+		if codigo, _ := postAPI(t, c.handler, c.ruta, `{`+c.base+`,"`+c.campo+`":-3}`); codigo != http.StatusBadRequest {
+			t.Errorf("%s %s -3: código %d, se esperaba 400", c.ruta, c.campo, codigo)
+		}
+		if codigo, _ := postAPI(t, c.handler, c.ruta, `{`+c.base); codigo != http.StatusBadRequest {
+			t.Errorf("%s JSON cortado: código %d, se esperaba 400", c.ruta, codigo)
+		}
+	}
+}
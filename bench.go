@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// -------------------- Modo benchmark --------------------
+
+// semillaBenchmark es la semilla de la primera corrida del benchmark cuando
+// los parámetros no indican una; la corrida i usa semilla+i
+const semillaBenchmark = 1
+
+// correrBenchmark corre n simulaciones OpenMP seguidas, sin pausas ni
+// servidor, y escribe en salida el tiempo total y el rendimiento. parametros
+// es un comando JSON como el de iniciar_openmp (vacío = valores por defecto).
+// Con perfilCPU y perfilMemoria escribe los perfiles de pprof de las
+// corridas (CPU y asignaciones).
+func correrBenchmark(n int, parametros, perfilCPU, perfilMemoria string, salida io.Writer) error {
+	if parametros == "" {
+		parametros = "{}"
+	}
+	c, err := decodificarComando([]byte(parametros))
+	if err != nil {
+		return fmt.Errorf("-bench-params: %w", err)
+	}
+	p := c.parametrosOpenMP()
+	p.Retardo = 0
+	if err := p.validar(); err != nil {
+		return fmt.Errorf("-bench-params: %w", err)
+	}
+	semilla := int64(semillaBenchmark)
+	if p.Semilla != nil {
+		semilla = *p.Semilla
+	}
+
+	if perfilCPU != "" {
+		f, err := os.Create(perfilCPU)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	var antes, despues runtime.MemStats
+	runtime.ReadMemStats(&antes)
+	mensajes := 0
+	inicio := time.Now()
+	for i := 0; i < n; i++ {
+		s := semilla + int64(i)
+		p.Semilla = &s
+		recorrerMensajes(context.Background(), func(ctx context.Context, enviar chan MensajeWS) {
+			correrOpenMP(ctx, p, nil, enviar)
+		}, func(MensajeWS) { mensajes++ })
+	}
+	total := time.Since(inicio)
+	runtime.ReadMemStats(&despues)
+
+	vueltas := n * p.Autos * p.Vueltas
+	fmt.Fprintf(salida, "corridas:         %d (%d autos, %d vueltas, semillas %d a %d)\n", n, p.Autos, p.Vueltas, semilla, semilla+int64(n)-1)
+	fmt.Fprintf(salida, "tiempo total:     %s\n", total)
+	fmt.Fprintf(salida, "por corrida:      %s\n", total/time.Duration(n))
+	fmt.Fprintf(salida, "corridas/s:       %.2f\n", float64(n)/total.Seconds())
+	fmt.Fprintf(salida, "vueltas/s:        %.0f\n", float64(vueltas)/total.Seconds())
+	fmt.Fprintf(salida, "mensajes/s:       %.0f (%d en total)\n", float64(mensajes)/total.Seconds(), mensajes)
+	fmt.Fprintf(salida, "bytes/corrida:    %d\n", (despues.TotalAlloc-antes.TotalAlloc)/uint64(n))
+	fmt.Fprintf(salida, "allocs/corrida:   %d\n", (despues.Mallocs-antes.Mallocs)/uint64(n))
+
+	if perfilMemoria != "" {
+		f, err := os.Create(perfilMemoria)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
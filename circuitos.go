@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// -------------------- Circuitos --------------------
+
+// RangoSector son los tiempos posibles de un sector de un circuito (s)
+type RangoSector struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Circuito es el perfil de tiempos de una pista: cuántos sectores tiene y
+// el rango de cada uno
+type Circuito struct {
+	Nombre   string        `json:"nombre"`
+	Sectores []RangoSector `json:"sectores"`
+}
+
+// circuitos son los perfiles que acepta el parámetro "circuito" de MPI, por
+// nombre en minúsculas. Para agregar una pista basta con sumarla al mapa.
+var circuitos = map[string]Circuito{
+	"monaco": {Nombre: "Monaco", Sectores: []RangoSector{
+		{Min: 19.5, Max: 22.0},
+		{Min: 33.0, Max: 37.0},
+		{Min: 18.5, Max: 21.0},
+	}},
+	"monza": {Nombre: "Monza", Sectores: []RangoSector{
+		{Min: 19.5, Max: 21.5},
+		{Min: 19.0, Max: 21.0},
+		{Min: 20.0, Max: 22.0},
+		{Min: 20.0, Max: 23.0},
+	}},
+	"spa": {Nombre: "Spa", Sectores: []RangoSector{
+		{Min: 18.0, Max: 20.0},
+		{Min: 22.0, Max: 24.5},
+		{Min: 24.0, Max: 27.0},
+		{Min: 21.0, Max: 23.5},
+		{Min: 18.5, Max: 20.5},
+	}},
+}
+
+// buscarCircuito devuelve el circuito de nombre (sin distinguir mayúsculas)
+func buscarCircuito(nombre string) (Circuito, bool) {
+	c, ok := circuitos[strings.ToLower(nombre)]
+	return c, ok
+}
+
+// nombresCircuitos lista los circuitos disponibles, ordenados
+func nombresCircuitos() []string {
+	nombres := make([]string, 0, len(circuitos))
+	for nombre := range circuitos {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
+
+// conCircuito devuelve p con la cantidad de sectores del circuito elegido,
+// que reemplaza a la indicada. Sin circuito, o si no existe (lo rechaza
+// validar), p queda igual.
+func (p ParametrosMPI) conCircuito() ParametrosMPI {
+	if c, ok := buscarCircuito(p.Circuito); ok {
+		p.Circuito = c.Nombre
+		p.Sectores = len(c.Sectores)
+	}
+	return p
+}
+
+// validarCircuito acepta un circuito vacío o uno de circuitos
+func validarCircuito(nombre string) error {
+	if _, ok := buscarCircuito(nombre); nombre != "" && !ok {
+		return fmt.Errorf("circuito desconocido %q: usar %s", nombre, strings.Join(nombresCircuitos(), ", "))
+	}
+	return nil
+}
+
+// rangoSector devuelve los tiempos posibles del sector s (desde 1): los del
+// circuito si se eligió uno, o tiempo_min/tiempo_max para todos los sectores
+func (p ParametrosMPI) rangoSector(s int) (minimo, maximo float64) {
+	if c, ok := buscarCircuito(p.Circuito); ok && s <= len(c.Sectores) {
+		return c.Sectores[s-1].Min, c.Sectores[s-1].Max
+	}
+	return p.TiempoMin, p.TiempoMax
+}
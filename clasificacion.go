@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// -------------------- Clasificación (una vuelta lanzada) --------------------
+
+// armarParrilla ordena los tiempos (tiempos[i] del auto i+1) de más rápido a
+// más lento y calcula la diferencia de cada auto con la pole. Ante un empate
+// queda adelante el auto de menor número.
+func armarParrilla(tiempos []float64, p ParametrosOpenMP) []ResultadoClasificacion {
+	parrilla := make([]ResultadoClasificacion, len(tiempos))
+	for i, t := range tiempos {
+		parrilla[i] = ResultadoClasificacion{AutoID: i + 1, Tiempo: t, BaseOffset: p.offsetAuto(i)}
+	}
+	sort.SliceStable(parrilla, func(i, j int) bool { return parrilla[i].Tiempo < parrilla[j].Tiempo })
+	for i := range parrilla {
+		parrilla[i].Posicion = i + 1
+		if i > 0 {
+			parrilla[i].Diferencia = redondear(parrilla[i].Tiempo - parrilla[0].Tiempo)
+			parrilla[i].Intervalo = fmt.Sprintf("+%.3f", parrilla[i].Diferencia)
+		}
+	}
+	return parrilla
+}
+
+// correrClasificacion corre una única vuelta lanzada por auto, todos en
+// paralelo, y arma la parrilla de salida. Usa los mismos parámetros que la
+// carrera OpenMP, pero ignora las vueltas, las paradas, el combustible, el
+// safety car y el límite de hilos. Si se cancela ctx los autos que no
+// terminaron abandonan.
+func correrClasificacion(ctx context.Context, p ParametrosOpenMP, pausa *compuerta, enviar chan MensajeWS) {
+	p.Vueltas = 1 // las vueltas no se usan, así que no se validan
+	if err := p.validar(); err != nil {
+		enviar <- mensajeError("openmp", err)
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "openmp"}
+		return
+	}
+
+	enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: fmt.Sprintf("Iniciando clasificación: %d autos, una vuelta lanzada cada uno", p.Autos)}
+	clima, multiplicador := anunciarClima(p.Clima, "openmp", enviar)
+
+	// Cada auto escribe solo su posición del slice, así no hace falta mutex
+	tiempos := make([]float64, p.Autos)
+	semilla := resolverSemilla(p.Semilla)
+	var wg sync.WaitGroup
+	for auto := 0; auto < p.Autos; auto++ {
+		wg.Add(1)
+		go func(autoID int) {
+			defer wg.Done()
+			aleatorio := rand.New(rand.NewSource(semilla + int64(autoID)))
+			tiempo := redondear(muestrear(p.Distribucion, p.TiempoMin, p.TiempoMax, aleatorio)*multiplicador + p.offsetAuto(autoID))
+			if !esperar(ctx, p.Retardo) || !pausa.pasar(ctx) {
+				return
+			}
+			tiempos[autoID] = tiempo
+			informarProgreso(ctx, 1, 0)
+			enviar <- MensajeWS{
+				Tipo:   "registro",
+				Topico: "openmp",
+				Texto:  fmt.Sprintf("Auto %d - Vuelta de clasificación: %s", autoID+1, formatearTiempo(tiempo, p.FormatoTiempo)),
+				Obj:    TiempoVueltaAuto{Auto: autoID + 1, Vuelta: 1, Tiempo: tiempo},
+			}
+		}(auto)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "openmp", Texto: "Clasificación detenida"}
+		return
+	}
+
+	parrilla := armarParrilla(tiempos, p)
+	resumen := ResumenClasificacion{Pole: parrilla[0], Parrilla: parrilla, Clima: clima}
+	enviar <- MensajeWS{
+		Tipo:   "registro",
+		Topico: "openmp",
+		Texto:  fmt.Sprintf("Pole position: Auto %d (%s)", resumen.Pole.AutoID, formatearTiempo(resumen.Pole.Tiempo, p.FormatoTiempo)),
+	}
+
+	var tabla strings.Builder
+	for _, r := range parrilla {
+		fmt.Fprintf(&tabla, "\n%d. Auto %d - %.2f s %s", r.Posicion, r.AutoID, r.Tiempo, r.Intervalo)
+	}
+	enviar <- MensajeWS{
+		Tipo:   "resumen",
+		Topico: "openmp",
+		Texto:  "Parrilla de salida:" + tabla.String(),
+		Obj:    resumen,
+	}
+	enviar <- MensajeWS{Tipo: "finalizado", Topico: "openmp", Texto: "Clasificación finalizada"}
+}
@@ -0,0 +1,165 @@
+// Package client se conecta por WebSocket al servidor de simulaciones, le
+// envía comandos y entrega los mensajes ya decodificados en los tipos del
+// paquete protocolo.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"formula-sim/protocolo"
+
+	"github.com/gorilla/websocket"
+)
+
+// esperaConexion es el plazo para completar el handshake del WebSocket
+const esperaConexion = 10 * time.Second
+
+// Cliente es una conexión al servidor. Sus métodos se pueden llamar desde
+// varias goroutines; los mensajes recibidos se leen de Mensajes.
+type Cliente struct {
+	conn      *websocket.Conn
+	escritura sync.Mutex // gorilla admite un solo escritor a la vez
+	mensajes  chan protocolo.MensajeWS
+	terminado chan struct{} // se cierra cuando leer termina, después de fijar err
+	cerrado   chan struct{} // lo cierra Cerrar, para que leer no espere a quien ya no lee Mensajes
+	cierre    sync.Once     // Cerrar se puede llamar más de una vez
+	err       error         // por qué se cortó la conexión
+}
+
+// Conectar abre el WebSocket en url (por ejemplo "ws://localhost:8080/ws")
+// pidiendo la versión protocolo.V1 del formato de mensajes, y empieza a
+// recibir mensajes
+func Conectar(url string) (*Cliente, error) {
+	marcador := websocket.Dialer{
+		HandshakeTimeout: esperaConexion,
+		Subprotocols:     []string{protocolo.V1},
+	}
+	conn, _, err := marcador.Dial(url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("conectar a %s: %w", url, err)
+	}
+	if conn.Subprotocol() != protocolo.V1 {
+		conn.Close()
+		return nil, fmt.Errorf("conectar a %s: el servidor no confirmó la versión %s", url, protocolo.V1)
+	}
+	c := &Cliente{conn: conn, mensajes: make(chan protocolo.MensajeWS), terminado: make(chan struct{}), cerrado: make(chan struct{})}
+	go c.leer()
+	return c, nil
+}
+
+// Mensajes devuelve el canal de los mensajes recibidos, en orden. Los
+// resúmenes fragmentados llegan ya armados como un único "resumen". El canal
+// se cierra al cortarse la conexión o con Cerrar (ver Err); mientras nadie lo
+// lea el cliente deja de leer del servidor.
+func (c *Cliente) Mensajes() <-chan protocolo.MensajeWS {
+	return c.mensajes
+}
+
+// Err devuelve por qué se cerró Mensajes; antes del cierre devuelve nil
+func (c *Cliente) Err() error {
+	select {
+	case <-c.terminado:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// leer decodifica los mensajes del servidor hasta que se corte la conexión
+func (c *Cliente) leer() {
+	defer close(c.mensajes)
+	defer close(c.terminado)
+	partes := map[string][]string{} // tramos de cada resumen fragmentado, por run_id
+	for {
+		var msg protocolo.MensajeWS
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			c.err = err
+			return
+		}
+		switch msg.Tipo {
+		case "resumen_parte":
+			tramos := partes[msg.RunID]
+			if len(tramos) < msg.Total {
+				tramos = append(tramos, make([]string, msg.Total-len(tramos))...)
+			}
+			if msg.Indice >= 1 && msg.Indice <= len(tramos) {
+				tramos[msg.Indice-1] = msg.Texto
+			}
+			partes[msg.RunID] = tramos
+			continue
+		case "resumen_fin":
+			var obj any
+			if err := json.Unmarshal([]byte(strings.Join(partes[msg.RunID], "")), &obj); err != nil {
+				c.err = fmt.Errorf("resumen fragmentado de %s: %w", msg.RunID, err)
+				c.conn.Close()
+				return
+			}
+			delete(partes, msg.RunID)
+			msg.Tipo, msg.Obj, msg.Total = "resumen", obj, 0
+		}
+		select {
+		case c.mensajes <- msg:
+		case <-c.cerrado:
+			c.err = net.ErrClosed
+			return
+		}
+	}
+}
+
+// Enviar manda un comando tal cual; comando debe tener "action" (ver GET
+// /api/comandos en el servidor)
+func (c *Cliente) Enviar(comando map[string]any) error {
+	c.escritura.Lock()
+	defer c.escritura.Unlock()
+	return c.conn.WriteJSON(comando)
+}
+
+// IniciarMPI inicia una simulación MPI de sectores con los valores por
+// defecto del servidor para el resto de los parámetros
+func (c *Cliente) IniciarMPI(sectores, vueltas int) error {
+	return c.Enviar(map[string]any{"action": "iniciar_mpi", "sectores": sectores, "vueltas": vueltas})
+}
+
+// IniciarOpenMP inicia una simulación OpenMP de autos en paralelo con los
+// valores por defecto del servidor para el resto de los parámetros
+func (c *Cliente) IniciarOpenMP(autos, vueltas int) error {
+	return c.Enviar(map[string]any{"action": "iniciar_openmp", "autos": autos, "vueltas": vueltas})
+}
+
+// Detener detiene la simulación del tópico indicado ("" = todas)
+func (c *Cliente) Detener(topico string) error {
+	return c.Enviar(map[string]any{"action": "detener", "topico": topico})
+}
+
+// PedirEstado pide las simulaciones en curso del servidor; la respuesta llega
+// por Mensajes como un mensaje "estado" con un []protocolo.EstadoCorrida
+func (c *Cliente) PedirEstado() error {
+	return c.Enviar(map[string]any{"action": "estado"})
+}
+
+// Cerrar avisa al servidor que se cierra la conexión y la cierra; Mensajes
+// se cierra poco después, aunque nadie lo esté leyendo
+func (c *Cliente) Cerrar() error {
+	c.cierre.Do(func() { close(c.cerrado) })
+	c.escritura.Lock()
+	aviso := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	c.conn.WriteControl(websocket.CloseMessage, aviso, time.Now().Add(time.Second))
+	c.escritura.Unlock()
+	return c.conn.Close()
+}
+
+// Decodificar copia el Obj de msg en destino, uno de los tipos de protocolo
+// según el mensaje (por ejemplo *protocolo.ResumenOpenMP para el resumen de
+// OpenMP, o *[]protocolo.MensajeWS para un "lote")
+func Decodificar(msg protocolo.MensajeWS, destino any) error {
+	datos, err := json.Marshal(msg.Obj)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(datos, destino)
+}
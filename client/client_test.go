@@ -0,0 +1,52 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"formula-sim/protocolo"
+
+	"github.com/gorilla/websocket"
+)
+
+// servidorContinuo levanta un WebSocket que negocia protocolo.V1 y envía
+// registros sin parar hasta que se corta la conexión
+func servidorContinuo(t *testing.T) string {
+	t.Helper()
+	actualizador := websocket.Upgrader{Subprotocols: []string{protocolo.V1}}
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := actualizador.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for conn.WriteJSON(protocolo.MensajeWS{Tipo: "registro", Topico: "mpi", Texto: "paso"}) == nil {
+		}
+	}))
+	t.Cleanup(servidor.Close)
+	return "ws" + strings.TrimPrefix(servidor.URL, "http")
+}
+
+func TestCerrarSinLeerMensajesTerminaLaLectura(t *testing.T) {
+	c, err := Conectar(servidorContinuo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Un mensaje leído asegura que leer ya está esperando para entregar el siguiente
+	<-c.Mensajes()
+	c.Cerrar()
+
+	select {
+	case <-c.terminado:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leer siguió bloqueada después de Cerrar")
+	}
+	if c.Err() == nil {
+		t.Error("Err es nil después de Cerrar")
+	}
+	for range c.Mensajes() {
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// -------------------- Cola de salida por conexión --------------------
+
+// maxMensajesPendientes es cuántos mensajes puede acumular una conexión
+// mientras el cliente no los lee, antes de empezar a omitir los de cada paso
+const maxMensajesPendientes = 100
+
+// omitible indica si un mensaje de tipo tipo se puede descartar con un
+// cliente lento: todos los de cada paso (registros, posiciones, lotes...)
+// menos el resumen, el finalizado y los errores de una corrida y las
+// respuestas a un comando (inicio, estado), que llegan una sola vez
+func omitible(tipo string) bool {
+	switch tipo {
+	case "resumen", "finalizado", "error", "inicio", "estado":
+		return false
+	}
+	return true
+}
+
+// colaSalida pasa los mensajes de entrada a salida sin bloquear nunca a
+// quien escribe en entrada. Si el cliente es lento y hay maxMensajesPendientes
+// esperando, se descarta el mensaje omitible más viejo (ver omitible). Los
+// omitidos se cuentan por run_id, un lote por cada evento que trae, y se
+// informan al final del Texto del resumen de esa corrida. Cierra salida
+// cuando se cierra entrada y se entregó todo lo pendiente.
+func colaSalida(entrada <-chan MensajeWS, salida chan<- MensajeWS, bitacora *slog.Logger) {
+	defer close(salida)
+	var pendientes []MensajeWS
+	omitidos := map[string]int{}
+	for entrada != nil || len(pendientes) > 0 {
+		// Sin pendientes el caso de envío queda deshabilitado (canal nil)
+		var destino chan<- MensajeWS
+		var siguiente MensajeWS
+		if len(pendientes) > 0 {
+			destino, siguiente = salida, pendientes[0]
+			if siguiente.Tipo == "resumen" && omitidos[siguiente.RunID] > 0 {
+				siguiente.Texto += fmt.Sprintf("\n%d mensajes omitidos", omitidos[siguiente.RunID])
+			}
+		}
+		select {
+		case msg, ok := <-entrada:
+			if !ok {
+				entrada = nil
+				continue
+			}
+			if len(pendientes) >= maxMensajesPendientes && omitible(msg.Tipo) {
+				pendientes = omitirMensaje(pendientes, omitidos, bitacora)
+			}
+			pendientes = append(pendientes, msg)
+		case destino <- siguiente:
+			if siguiente.Tipo == "resumen" {
+				delete(omitidos, siguiente.RunID)
+			}
+			pendientes = pendientes[1:]
+		}
+	}
+}
+
+// omitirMensaje quita el mensaje omitible más viejo de pendientes y cuenta en
+// omitidos los eventos que traía. Si no hay ninguno (solo mensajes que no se
+// omiten) no quita nada.
+func omitirMensaje(pendientes []MensajeWS, omitidos map[string]int, bitacora *slog.Logger) []MensajeWS {
+	for i, msg := range pendientes {
+		if omitible(msg.Tipo) {
+			eventos := 1
+			if lote, ok := msg.Obj.([]MensajeWS); ok && msg.Tipo == "lote" {
+				eventos = len(lote)
+			}
+			omitidos[msg.RunID] += eventos
+			bitacora.Debug("cliente lento, se omite un mensaje", "tipo", msg.Tipo, "eventos", eventos, "topico", msg.Topico, "run_id", msg.RunID)
+			metricas.mensajesOmitidos.Add(int64(eventos))
+			return append(pendientes[:i], pendientes[i+1:]...)
+		}
+	}
+	return pendientes
+}
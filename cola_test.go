@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mensajesClienteLento corre correr con su salida pasando por colaSalida y
+// lee lo que quedó pendiente recién cuando la simulación terminó
+func mensajesClienteLento(t *testing.T, correr func(enviar chan MensajeWS)) []MensajeWS {
+	t.Helper()
+	entrada, salida := make(chan MensajeWS), make(chan MensajeWS)
+	go colaSalida(entrada, salida, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	correr(entrada)
+	close(entrada)
+	var mensajes []MensajeWS
+	for msg := range salida {
+		mensajes = append(mensajes, msg)
+	}
+	return mensajes
+}
+
+// comprobarColaAcotada verifica que la cola no pasó de maxMensajesPendientes
+// más el resumen y el finalizado, que llegaron con los omitidos informados
+func comprobarColaAcotada(t *testing.T, mensajes []MensajeWS) {
+	t.Helper()
+	if len(mensajes) > maxMensajesPendientes+2 {
+		t.Errorf("se acumularon %d mensajes para un cliente que no lee, el máximo es %d", len(mensajes), maxMensajesPendientes)
+	}
+	var resumen, finalizado bool
+	for _, msg := range mensajes {
+		switch msg.Tipo {
+		case "resumen":
+			resumen = true
+			if !strings.Contains(msg.Texto, "mensajes omitidos") {
+				t.Errorf("el resumen no informa los mensajes omitidos: %q", msg.Texto)
+			}
+		case "finalizado":
+			finalizado = true
+		}
+	}
+	if !resumen || !finalizado {
+		t.Errorf("resumen %v, finalizado %v: se omitió un mensaje que siempre debe llegar", resumen, finalizado)
+	}
+}
+
+func TestColaSalidaOmiteMensajesDeCadaPaso(t *testing.T) {
+	mensajes := mensajesClienteLento(t, func(enviar chan MensajeWS) {
+		enviar <- MensajeWS{Tipo: "inicio", RunID: "a"}
+		for i := 0; i < 3*maxMensajesPendientes; i++ {
+			tipo := []string{"registro", "posiciones", "leaderboard_delta", "grilla"}[i%4]
+			enviar <- MensajeWS{Tipo: tipo, RunID: "a"}
+		}
+		enviar <- MensajeWS{Tipo: "resumen", RunID: "a"}
+		enviar <- MensajeWS{Tipo: "finalizado", RunID: "a"}
+	})
+	comprobarColaAcotada(t, mensajes)
+	if mensajes[0].Tipo != "inicio" {
+		t.Errorf("se omitió el inicio: llegó primero %q", mensajes[0].Tipo)
+	}
+}
+
+func TestColaSalidaOmiteLotes(t *testing.T) {
+	p := parametrosMPIPrueba(5, 60)
+	p.Retardo = time.Millisecond
+	mensajes := mensajesClienteLento(t, func(enviar chan MensajeWS) {
+		lote, listo := agruparEnLotes(time.Millisecond, enviar)
+		correrMPI(context.Background(), p, nil, lote)
+		close(lote)
+		<-listo
+	})
+	comprobarColaAcotada(t, mensajes)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// -------------------- Descripción de comandos --------------------
+
+// velocidadPorDefecto es el ritmo de "reproducir" cuando no se indica velocidad
+const velocidadPorDefecto = 1.0
+
+// DescripcionComando describe una acción WebSocket para GET /api/comandos
+type DescripcionComando struct {
+	Action      string                 `json:"action"`
+	Descripcion string                 `json:"descripcion"`
+	Parametros  []DescripcionParametro `json:"parametros"`
+}
+
+// DescripcionParametro describe un parámetro de una acción
+type DescripcionParametro struct {
+	Nombre      string `json:"nombre"`
+	Tipo        string `json:"tipo"`
+	Defecto     any    `json:"defecto,omitempty"`
+	Limites     string `json:"limites,omitempty"`
+	Descripcion string `json:"descripcion"`
+}
+
+// acciones es la lista de comandos aceptados por procesarComando, en el orden
+// en que se describen
+var acciones = []struct{ nombre, descripcion string }{
+	{"iniciar_mpi", "inicia la simulación MPI de sectores"},
+	{"iniciar_openmp", "inicia la carrera OpenMP"},
+	{"iniciar_clasificacion", "corre una vuelta lanzada por auto y arma la parrilla"},
+	{"iniciar_sesion", "corre la clasificación y después la sesión MPI, con un resumen combinado"},
+	{"iniciar_anillo", "inicia el anillo de nodos"},
+	{"comparar_openmp", "corre dos configuraciones OpenMP a la vez, con la misma semilla, y las compara"},
+	{"escenario", "corre una carrera preparada, siempre igual (misma semilla)"},
+	{"reproducir", "vuelve a emitir una corrida terminada"},
+	{"detener", "cancela una simulación en curso"},
+	{"pausar", "congela una simulación MPI u OpenMP"},
+	{"reanudar", "continúa una simulación en pausa"},
+	{"reiniciar", "borra los resultados y grabaciones guardados"},
+	{"estado", "informa las simulaciones en curso del servidor, con sus parámetros y hasta dónde llegaron"},
+}
+
+// limitesParametros describe los valores válidos de cada parámetro, según
+// las validaciones de cada simulación
+func limitesParametros() map[string]string {
+	climas := make([]string, 0, len(multiplicadoresClima))
+	for clima := range multiplicadoresClima {
+		climas = append(climas, clima)
+	}
+	sort.Strings(climas)
+	return map[string]string{
+		"topico":              "mpi, openmp, anillo, reproduccion, sesion o comparar (pausar/reanudar: mpi, openmp, sesion o comparar)",
+		"sectores":            fmt.Sprintf("1 a %d", configuracion.MaxSectores),
+		"vueltas":             fmt.Sprintf("1 a %d", configuracion.MaxVueltas),
+		"autos":               fmt.Sprintf("1 a %d", configuracion.MaxAutos),
+		"tiempo_min":          "> 0 y menor que tiempo_max",
+		"tiempo_max":          "> 0 y mayor que tiempo_min",
+		"delay_ms":            ">= 0",
+		"degradacion":         ">= 0",
+		"bandera_roja_umbral": ">= 0 (0 = sin bandera roja)",
+		"clima":               strings.Join(climas, ", "),
+		"formato_tiempo":      "s, ms o mm:ss.mmm",
+		"distribucion":        "uniforme, normal o triangular",
+		"vista":               "filas o grilla",
+		"circuito":            strings.Join(nombresCircuitos(), ", "),
+		"pit_cada":            "0 o >= 2",
+		"pit_tiempo":          ">= 0",
+		"combustible_inicial": ">= 0",
+		"safety_car_prob":     "0 a 1",
+		"desgaste_motor":      ">= 0",
+		"prob_abandono":       "0 a 1",
+		"puntos":              "cada uno >= 0 ([] = sin puntos por posición)",
+		"hilos":               ">= 0",
+		"autos_config":        "vacío o un elemento por auto",
+		"nodos":               fmt.Sprintf("2 a %d", configuracion.MaxNodos),
+		"vueltas_anillo":      ">= 0",
+		"variante":            "clasica o coordinador",
+		"nombre":              strings.Join(nombresEscenarios(), ", "),
+		"duracion_seg":        "> 0",
+		"velocidad":           "> 0",
+		"lote_ms":             ">= 0 (0 = un mensaje por evento)",
+		"factor_velocidad":    fmt.Sprintf("%g a %g", factorVelocidadMin, factorVelocidadMax),
+	}
+}
+
+// valoresPorDefecto devuelve, por nombre de parámetro, los valores que usa
+// accion cuando el cliente no los indica. Salen de los mismos constructores
+// que usa procesarComando, así no pueden desfasarse.
+func valoresPorDefecto(accion string) map[string]any {
+	var p any
+	retardo := 0.0
+	switch accion {
+	case "iniciar_mpi", "iniciar_sesion":
+		mpi := Comando{}.parametrosMPI()
+		p, retardo = mpi, float64(mpi.Retardo.Milliseconds())
+	case "iniciar_openmp", "iniciar_clasificacion":
+		openmp := Comando{}.parametrosOpenMP()
+		p, retardo = openmp, float64(openmp.Retardo.Milliseconds())
+	case "iniciar_anillo":
+		anillo := Comando{}.parametrosAnillo()
+		return map[string]any{"nodos": anillo.Nodos, "vueltas_anillo": anillo.Vueltas, "duracion_seg": anillo.Duracion.Seconds(), "delay_ms": float64(anillo.Retardo.Milliseconds()), "difundir": false, "lote_ms": 0.0, "factor_velocidad": 1.0}
+	case "reproducir":
+		return map[string]any{"velocidad": velocidadPorDefecto, "difundir": false, "lote_ms": 0.0}
+	case "escenario", "comparar_openmp":
+		return map[string]any{"difundir": false, "lote_ms": 0.0, "factor_velocidad": 1.0}
+	default:
+		return nil
+	}
+	// Los parámetros tienen las mismas etiquetas JSON que Comando (salvo el retardo)
+	datos, _ := json.Marshal(p)
+	var valores map[string]any
+	json.Unmarshal(datos, &valores)
+	valores["delay_ms"] = retardo
+	valores["difundir"] = false
+	valores["lote_ms"] = 0.0
+	valores["factor_velocidad"] = 1.0
+	if accion == "iniciar_sesion" {
+		valores["autos"] = Comando{}.parametrosOpenMP().Autos
+	}
+	if valores["clima"] == "" {
+		valores["clima"] = climaPorDefecto
+	}
+	return valores
+}
+
+// describirComandos arma la descripción de cada acción a partir de los campos
+// de Comando y sus etiquetas acciones y desc
+func describirComandos() []DescripcionComando {
+	limites := limitesParametros()
+	tipo := reflect.TypeOf(Comando{})
+	descripciones := make([]DescripcionComando, 0, len(acciones))
+	for _, accion := range acciones {
+		defectos := valoresPorDefecto(accion.nombre)
+		d := DescripcionComando{Action: accion.nombre, Descripcion: accion.descripcion, Parametros: []DescripcionParametro{}}
+		for i := 0; i < tipo.NumField(); i++ {
+			campo := tipo.Field(i)
+			usadoPor := strings.Split(campo.Tag.Get("acciones"), ",")
+			if !slices.Contains(usadoPor, accion.nombre) {
+				continue
+			}
+			nombre := strings.Split(campo.Tag.Get("json"), ",")[0]
+			tipoCampo := campo.Type
+			if tipoCampo.Kind() == reflect.Pointer {
+				tipoCampo = tipoCampo.Elem()
+			}
+			d.Parametros = append(d.Parametros, DescripcionParametro{
+				Nombre:      nombre,
+				Tipo:        nombreTipo(tipoCampo),
+				Defecto:     defectos[nombre],
+				Limites:     limites[nombre],
+				Descripcion: campo.Tag.Get("desc"),
+			})
+		}
+		descripciones = append(descripciones, d)
+	}
+	return descripciones
+}
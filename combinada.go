@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// -------------------- Sesión combinada (clasificación + MPI) --------------------
+
+// correrFase corre una fase de la sesión combinada y reenvía sus mensajes a
+// enviar tal cual, salvo el finalizado de la fase, que pasa como registro
+// para que el cliente reciba un único finalizado al terminar la sesión.
+// Devuelve el Obj del resumen de la fase, o nil si no llegó a emitirlo
+// (error de validación o cancelación).
+func correrFase(ctx context.Context, correr func(ctx context.Context, enviar chan MensajeWS), enviar chan MensajeWS) any {
+	fase := make(chan MensajeWS)
+	go func() {
+		defer close(fase)
+		correr(ctx, fase)
+	}()
+	var resumen any
+	for msg := range fase {
+		switch msg.Tipo {
+		case "resumen":
+			resumen = msg.Obj
+		case "finalizado":
+			msg.Tipo = "registro"
+		}
+		enviar <- msg
+	}
+	return resumen
+}
+
+// correrCombinada corre la clasificación OpenMP y después la sesión MPI,
+// una detrás de otra, y termina con un resumen que junta los dos.
+// Cada fase informa en su propio tópico; el resumen y el finalizado de la
+// sesión van en el tópico "sesion". Si una fase no termina, la sesión se corta.
+func correrCombinada(ctx context.Context, po ParametrosOpenMP, pm ParametrosMPI, pausa *compuerta, enviar chan MensajeWS) {
+	for _, err := range []error{po.validar(), pm.validar()} {
+		if err != nil {
+			enviar <- mensajeError("sesion", err)
+			enviar <- MensajeWS{Tipo: "finalizado", Topico: "sesion"}
+			return
+		}
+	}
+
+	enviar <- MensajeWS{Tipo: "registro", Topico: "sesion", Texto: "Sesión: clasificación OpenMP y después sectores MPI"}
+	clasificacion, ok := correrFase(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+		correrClasificacion(ctx, po, pausa, enviar)
+	}, enviar).(ResumenClasificacion)
+	if !ok {
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "sesion", Texto: "Sesión detenida durante la clasificación"}
+		return
+	}
+	mpi, ok := correrFase(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+		correrMPI(ctx, pm, pausa, enviar)
+	}, enviar).(ResumenMPI)
+	if !ok {
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "sesion", Texto: "Sesión detenida durante la sesión MPI"}
+		return
+	}
+
+	enviar <- MensajeWS{
+		Tipo:   "resumen",
+		Topico: "sesion",
+		Texto: fmt.Sprintf("Resultados de la sesión: pole para el Auto %d (%.2f s); MPI %.2f s en %d vueltas (mejor vuelta %.2f s)",
+			clasificacion.Pole.AutoID, clasificacion.Pole.Tiempo, mpi.TiempoTotal, len(mpi.Vueltas), mpi.MejorVuelta.Tiempo),
+		Obj: ResumenCombinado{Clasificacion: clasificacion, MPI: mpi},
+	}
+	enviar <- MensajeWS{Tipo: "finalizado", Topico: "sesion", Texto: "Sesión finalizada"}
+}
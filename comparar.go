@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// -------------------- Comparación de dos configuraciones OpenMP --------------------
+
+// compararOpenMP arma la comparación de dos carreras terminadas
+func compararOpenMP(a, b ResumenOpenMP) ComparacionOpenMP {
+	c := ComparacionOpenMP{A: a, B: b, MasRapida: "empate"}
+	mejorA, mejorB := a.MejorGeneral, b.MejorGeneral
+	switch {
+	case mejorA != nil && mejorB != nil:
+		c.DiferenciaMejorVuelta = redondear(mejorB.MejorVuelta - mejorA.MejorVuelta)
+		if c.DiferenciaMejorVuelta < 0 {
+			c.MasRapida = "b"
+		} else if c.DiferenciaMejorVuelta > 0 {
+			c.MasRapida = "a"
+		}
+	case mejorA != nil:
+		c.MasRapida = "a"
+	case mejorB != nil:
+		c.MasRapida = "b"
+	}
+	c.DiferenciaPromedio = redondear(b.Estadisticas.Media - a.Estadisticas.Media)
+	if len(a.OrdenLlegada) > 0 && len(b.OrdenLlegada) > 0 && !a.OrdenLlegada[0].Abandono && !b.OrdenLlegada[0].Abandono {
+		c.DiferenciaGanador = redondear(b.OrdenLlegada[0].TiempoTotal - a.OrdenLlegada[0].TiempoTotal)
+	}
+	return c
+}
+
+// correrComparacion corre las carreras pa y pb a la vez, cada una con su
+// tópico (pa.Topico y pb.Topico), y termina con un resumen que las compara
+// en el tópico "comparar". Como en la sesión combinada, los finalizado de
+// cada carrera pasan como registros y el cliente recibe uno solo al final.
+func correrComparacion(ctx context.Context, pa, pb ParametrosOpenMP, pausa *compuerta, enviar chan MensajeWS) {
+	for i, p := range []ParametrosOpenMP{pa, pb} {
+		if err := p.validar(); err != nil {
+			enviar <- mensajeError("comparar", fmt.Errorf("%s: %w", []string{"a", "b"}[i], err))
+			enviar <- MensajeWS{Tipo: "finalizado", Topico: "comparar"}
+			return
+		}
+	}
+
+	enviar <- MensajeWS{
+		Tipo:   "registro",
+		Topico: "comparar",
+		Texto: fmt.Sprintf("Comparando OpenMP: a (%d autos, %d vueltas) en %s contra b (%d autos, %d vueltas) en %s",
+			pa.Autos, pa.Vueltas, pa.topico(), pb.Autos, pb.Vueltas, pb.topico()),
+	}
+	var resumenA, resumenB any
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		resumenA = correrFase(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+			correrOpenMP(ctx, pa, pausa, enviar)
+		}, enviar)
+	}()
+	go func() {
+		defer wg.Done()
+		resumenB = correrFase(ctx, func(ctx context.Context, enviar chan MensajeWS) {
+			correrOpenMP(ctx, pb, pausa, enviar)
+		}, enviar)
+	}()
+	wg.Wait()
+
+	a, okA := resumenA.(ResumenOpenMP)
+	b, okB := resumenB.(ResumenOpenMP)
+	if !okA || !okB {
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "comparar", Texto: "Comparación detenida"}
+		return
+	}
+	comparacion := compararOpenMP(a, b)
+	ganadora := "empate en la mejor vuelta"
+	if comparacion.MasRapida != "empate" {
+		ganadora = "mejor vuelta más rápida en " + comparacion.MasRapida
+	}
+	enviar <- MensajeWS{
+		Tipo:   "resumen",
+		Topico: "comparar",
+		Texto: fmt.Sprintf("Comparación OpenMP: %s (b - a: %+.2f s mejor vuelta, %+.2f s promedio, %+.2f s ganador)",
+			ganadora, comparacion.DiferenciaMejorVuelta, comparacion.DiferenciaPromedio, comparacion.DiferenciaGanador),
+		Obj: comparacion,
+	}
+	enviar <- MensajeWS{Tipo: "finalizado", Topico: "comparar", Texto: "Comparación finalizada"}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// -------------------- Configuración del servidor --------------------
+
+// Config son los valores por defecto que usan los comandos cuando el cliente
+// no los indica y los límites de las simulaciones, para que un cliente no
+// agote los recursos del servidor. Se cargan al arrancar (ver cargarConfig).
+type Config struct {
+	Sectores      int     `json:"sectores"`        // sectores de MPI
+	VueltasMPI    int     `json:"vueltas_mpi"`     // vueltas de MPI
+	Autos         int     `json:"autos"`           // autos de OpenMP y clasificación
+	VueltasOpenMP int     `json:"vueltas_openmp"`  // vueltas de OpenMP
+	DelaySectorMs float64 `json:"delay_sector_ms"` // pausa entre sectores de MPI
+	DelayVueltaMs float64 `json:"delay_vuelta_ms"` // pausa entre vueltas de OpenMP
+
+	MaxSectores int `json:"max_sectores"`
+	MaxAutos    int `json:"max_autos"`
+	MaxVueltas  int `json:"max_vueltas"`
+	MaxNodos    int `json:"max_nodos"`    // nodos del anillo
+	MaxMensajes int `json:"max_mensajes"` // mensajes por simulación (ver limiteMensajes)
+}
+
+// configPorDefecto es la configuración cuando no hay archivo o no indica un campo
+func configPorDefecto() Config {
+	return Config{
+		Sectores:      5,
+		VueltasMPI:    3,
+		Autos:         4,
+		VueltasOpenMP: 5,
+		DelaySectorMs: 300,
+		DelayVueltaMs: 200,
+		MaxSectores:   1000,
+		MaxAutos:      64,
+		MaxVueltas:    10000,
+		MaxNodos:      100,
+		MaxMensajes:   maxMensajesPorCorrida,
+	}
+}
+
+// configuracion es la configuración en uso; main la reemplaza por la del
+// archivo antes de atender peticiones
+var configuracion = configPorDefecto()
+
+// cargarConfig lee la configuración de ruta. Los campos que el archivo no
+// indica conservan su valor por defecto y, si el archivo no existe, se usa
+// configPorDefecto. Un JSON mal formado, un campo desconocido o un valor
+// fuera de rango son error.
+func cargarConfig(ruta string) (Config, error) {
+	c := configPorDefecto()
+	datos, err := os.ReadFile(ruta)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	decodificador := json.NewDecoder(bytes.NewReader(datos))
+	decodificador.DisallowUnknownFields()
+	if err := decodificador.Decode(&c); err != nil {
+		return c, fmt.Errorf("%s: %w", ruta, err)
+	}
+	if err := c.validar(); err != nil {
+		return c, fmt.Errorf("%s: %w", ruta, err)
+	}
+	return c, nil
+}
+
+// validar comprueba que los límites sean positivos y que los valores por
+// defecto los respeten
+func (c Config) validar() error {
+	switch {
+	case c.MaxSectores < 1 || c.MaxAutos < 1 || c.MaxVueltas < 1:
+		return fmt.Errorf("max_sectores, max_autos y max_vueltas deben ser >= 1")
+	case c.MaxNodos < 2:
+		return fmt.Errorf("max_nodos debe ser >= 2")
+	case c.MaxMensajes < 1:
+		return fmt.Errorf("max_mensajes debe ser >= 1")
+	case c.Sectores < 1 || c.Sectores > c.MaxSectores:
+		return fmt.Errorf("sectores debe estar entre 1 y max_sectores (%d)", c.MaxSectores)
+	case c.Autos < 1 || c.Autos > c.MaxAutos:
+		return fmt.Errorf("autos debe estar entre 1 y max_autos (%d)", c.MaxAutos)
+	case c.VueltasMPI < 1 || c.VueltasMPI > c.MaxVueltas:
+		return fmt.Errorf("vueltas_mpi debe estar entre 1 y max_vueltas (%d)", c.MaxVueltas)
+	case c.VueltasOpenMP < 1 || c.VueltasOpenMP > c.MaxVueltas:
+		return fmt.Errorf("vueltas_openmp debe estar entre 1 y max_vueltas (%d)", c.MaxVueltas)
+	case c.DelaySectorMs < 0 || c.DelayVueltaMs < 0:
+		return fmt.Errorf("delay_sector_ms y delay_vuelta_ms deben ser >= 0")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// -------------------- Escenarios --------------------
+
+// Escenario es una carrera preparada para demostraciones: un comando con la
+// semilla fija, así se ve exactamente la misma carrera cada vez
+type Escenario struct {
+	Descripcion string
+	Comando     string // JSON del comando que corre, como lo enviaría un cliente
+}
+
+// escenarios son los que acepta el comando "escenario", por nombre. Para
+// agregar uno basta con sumarlo al mapa; siempre con semilla.
+var escenarios = map[string]Escenario{
+	"clasico_monza": {
+		Descripcion: "una vuelta de 3 sectores, sin sorpresas",
+		Comando:     `{"action": "iniciar_mpi", "sectores": 3, "vueltas": 1, "semilla": 1950}`,
+	},
+	"lluvia_spa": {
+		Descripcion: "tres vueltas en Spa bajo lluvia, con degradación",
+		Comando:     `{"action": "iniciar_mpi", "circuito": "spa", "clima": "lluvia", "vueltas": 3, "degradacion": 0.2, "semilla": 1998}`,
+	},
+	"duelo_openmp": {
+		Descripcion: "dos autos casi iguales, cinco vueltas",
+		Comando:     `{"action": "iniciar_openmp", "autos": 2, "vueltas": 5, "autos_config": [{"base_offset": 0}, {"base_offset": 0.3}], "semilla": 2021}`,
+	},
+	"carrera_boxes": {
+		Descripcion: "seis autos, paradas en boxes y safety car",
+		Comando:     `{"action": "iniciar_openmp", "autos": 6, "vueltas": 12, "pit_cada": 4, "safety_car_prob": 0.1, "semilla": 2008}`,
+	},
+	"sesion_monaco": {
+		Descripcion: "clasificación de cinco autos y sesión en Monaco",
+		Comando:     `{"action": "iniciar_sesion", "autos": 5, "circuito": "monaco", "vueltas": 2, "semilla": 1984}`,
+	},
+}
+
+// nombresEscenarios lista los escenarios disponibles, ordenados
+func nombresEscenarios() []string {
+	nombres := make([]string, 0, len(escenarios))
+	for nombre := range escenarios {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
+
+// comandoEscenario decodifica el comando del escenario nombre
+func comandoEscenario(nombre string) (Escenario, Comando, error) {
+	e, ok := escenarios[nombre]
+	if !ok {
+		return Escenario{}, Comando{}, fmt.Errorf("escenario desconocido %q: usar %s", nombre, strings.Join(nombresEscenarios(), ", "))
+	}
+	c, err := decodificarComando([]byte(e.Comando))
+	if err != nil {
+		return Escenario{}, Comando{}, fmt.Errorf("escenario %s mal definido: %w", nombre, err)
+	}
+	return e, c, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// -------------------- Resúmenes fragmentados --------------------
+
+// maxTamanioResumen es el tamaño máximo en bytes del obj de un resumen que
+// se envía en un solo mensaje WebSocket, y el de cada parte si se fragmenta
+const maxTamanioResumen = 32 << 10
+
+// fragmentarResumen deja msg tal cual salvo que sea un "resumen" cuyo obj en
+// JSON ocupe más de maximo bytes. En ese caso lo reparte en mensajes
+// "resumen_parte" con Indice (desde 1) y Total, cuyo Texto es un tramo del
+// JSON del obj, seguidos de un "resumen_fin" con el Texto original y Total.
+// El cliente concatena los Texto de las partes y decodifica el obj.
+func fragmentarResumen(msg MensajeWS, maximo int) []MensajeWS {
+	if msg.Tipo != "resumen" || msg.Obj == nil {
+		return []MensajeWS{msg}
+	}
+	datos, err := json.Marshal(msg.Obj)
+	if err != nil || len(datos) <= maximo {
+		return []MensajeWS{msg}
+	}
+
+	var tramos []string
+	for len(datos) > 0 {
+		fin := min(maximo, len(datos))
+		// No corta un carácter UTF-8 a la mitad (el JSON lo reemplazaría); si
+		// maximo no alcanza para uno, el tramo lleva el carácter entero
+		for fin < len(datos) && fin > 0 && !utf8.RuneStart(datos[fin]) {
+			fin--
+		}
+		if fin == 0 {
+			_, fin = utf8.DecodeRune(datos)
+		}
+		tramos = append(tramos, string(datos[:fin]))
+		datos = datos[fin:]
+	}
+	partes := make([]MensajeWS, 0, len(tramos)+1)
+	for i, tramo := range tramos {
+		partes = append(partes, MensajeWS{
+			Tipo:    "resumen_parte",
+			Topico:  msg.Topico,
+			Texto:   tramo,
+			RunID:   msg.RunID,
+			MsEpoch: msg.MsEpoch,
+			Indice:  i + 1,
+			Total:   len(tramos),
+		})
+	}
+	return append(partes, MensajeWS{
+		Tipo:    "resumen_fin",
+		Topico:  msg.Topico,
+		Texto:   msg.Texto,
+		RunID:   msg.RunID,
+		MsEpoch: msg.MsEpoch,
+		Total:   len(tramos),
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// -------------------- Grabaciones de corridas --------------------
+
+// Límites de lo que se guarda para reproducir: las corridas más viejas se
+// descartan y una corrida muy larga solo guarda sus primeros mensajes.
+const (
+	maxGrabaciones      = 20
+	maxMensajesGrabados = 5000
+)
+
+// grabacion son los mensajes de una corrida terminada, con su RunID y MsEpoch
+type grabacion struct {
+	mensajes []MensajeWS
+	truncada bool // se superó maxMensajesGrabados y faltan mensajes del final
+
+	resumen *MensajeWS // último resumen de la corrida, aunque esté truncada
+}
+
+// grabaciones guarda las últimas corridas por run_id, en orden de llegada
+var grabaciones = struct {
+	sync.Mutex
+	porRunID map[string]grabacion
+	orden    []string
+}{porRunID: map[string]grabacion{}}
+
+// guardarGrabacion registra la corrida runID y descarta la más vieja si se
+// supera maxGrabaciones
+func guardarGrabacion(runID string, g grabacion) {
+	grabaciones.Lock()
+	defer grabaciones.Unlock()
+	grabaciones.porRunID[runID] = g
+	grabaciones.orden = append(grabaciones.orden, runID)
+	if len(grabaciones.orden) > maxGrabaciones {
+		delete(grabaciones.porRunID, grabaciones.orden[0])
+		grabaciones.orden = grabaciones.orden[1:]
+	}
+}
+
+// obtenerGrabacion devuelve la corrida runID, si todavía está guardada
+func obtenerGrabacion(runID string) (grabacion, bool) {
+	grabaciones.Lock()
+	defer grabaciones.Unlock()
+	g, ok := grabaciones.porRunID[runID]
+	return g, ok
+}
+
+// borrarGrabaciones descarta todas las corridas guardadas y devuelve cuántas eran
+func borrarGrabaciones() int {
+	grabaciones.Lock()
+	defer grabaciones.Unlock()
+	cantidad := len(grabaciones.orden)
+	grabaciones.porRunID = map[string]grabacion{}
+	grabaciones.orden = nil
+	return cantidad
+}
+
+// reproducir reenvía los mensajes de g respetando el tiempo original entre
+// ellos, dividido por velocidad (2 = el doble de rápido). Se detiene si se
+// cancela ctx.
+func reproducir(ctx context.Context, runID string, g grabacion, velocidad float64, enviar chan MensajeWS) {
+	topico := g.mensajes[0].Topico
+	enviar <- MensajeWS{
+		Tipo:   "registro",
+		Topico: topico,
+		Texto:  fmt.Sprintf("Reproduciendo run_id %s (%d mensajes, velocidad x%g)", runID, len(g.mensajes), velocidad),
+	}
+	anterior := int64(0)
+	for _, msg := range g.mensajes {
+		espera := time.Duration(float64(msg.MsEpoch-anterior) * float64(time.Millisecond) / velocidad)
+		if !esperar(ctx, espera) {
+			enviar <- MensajeWS{Tipo: "finalizado", Topico: topico, Texto: "Reproducción detenida"}
+			return
+		}
+		anterior = msg.MsEpoch
+		enviar <- msg
+	}
+	if g.truncada {
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: topico, Texto: "Reproducción incompleta: la corrida superó el máximo de mensajes guardados"}
+	}
+}
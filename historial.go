@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -------------------- Historial de corridas --------------------
+
+// CorridaGuardada es una corrida terminada tal como queda en el historial
+type CorridaGuardada struct {
+	ID         string          `json:"id"`   // run_id de la corrida
+	Tipo       string          `json:"tipo"` // acción que la inició ("iniciar_mpi", ...)
+	Fecha      time.Time       `json:"fecha"`
+	DuracionMs int64           `json:"duracion_ms"`
+	Semilla    *int64          `json:"semilla,omitempty"`
+	Parametros json.RawMessage `json:"parametros"`
+	Resultado  string          `json:"resultado"`         // resultados principales, en texto (ver resultadoClave)
+	Resumen    json.RawMessage `json:"resumen,omitempty"` // Obj del resumen; solo en el detalle
+}
+
+// historial guarda las corridas terminadas para consultarlas después, aunque
+// se reinicie el servidor
+type historial interface {
+	guardar(c CorridaGuardada) error
+	ultimas(n int) []CorridaGuardada // la más reciente primero, sin Resumen
+	buscar(id string) (CorridaGuardada, bool)
+}
+
+// maxHistorial es cuántas corridas se conservan; se descartan las más viejas
+const maxHistorial = 200
+
+// historialArchivo guarda el historial completo en un archivo JSON, que se
+// reescribe entero en cada corrida (son pocas y chicas). Con ruta vacía el
+// historial vive solo en memoria.
+type historialArchivo struct {
+	mu       sync.Mutex
+	ruta     string
+	corridas []CorridaGuardada // de la más vieja a la más nueva
+}
+
+// abrirHistorialArchivo carga el historial de ruta; si el archivo no existe
+// empieza vacío y lo crea con la primera corrida
+func abrirHistorialArchivo(ruta string) (*historialArchivo, error) {
+	h := &historialArchivo{ruta: ruta}
+	datos, err := os.ReadFile(ruta)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(datos, &h.corridas); err != nil {
+		return nil, fmt.Errorf("historial %s dañado: %v", ruta, err)
+	}
+	return h, nil
+}
+
+func (h *historialArchivo) guardar(c CorridaGuardada) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.corridas = append(h.corridas, c)
+	if len(h.corridas) > maxHistorial {
+		h.corridas = h.corridas[len(h.corridas)-maxHistorial:]
+	}
+	if h.ruta == "" {
+		return nil
+	}
+	datos, err := json.Marshal(h.corridas)
+	if err != nil {
+		return err
+	}
+	// Se escribe aparte y se renombra, así un corte no deja el archivo a medias
+	temporal, err := os.CreateTemp(filepath.Dir(h.ruta), ".historial-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporal.Name())
+	if _, err := temporal.Write(datos); err != nil {
+		temporal.Close()
+		return err
+	}
+	if err := temporal.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporal.Name(), h.ruta)
+}
+
+func (h *historialArchivo) ultimas(n int) []CorridaGuardada {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n = min(n, len(h.corridas))
+	ultimas := make([]CorridaGuardada, 0, n)
+	for i := len(h.corridas) - 1; i >= len(h.corridas)-n; i-- {
+		c := h.corridas[i]
+		c.Resumen = nil
+		ultimas = append(ultimas, c)
+	}
+	return ultimas
+}
+
+func (h *historialArchivo) buscar(id string) (CorridaGuardada, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.corridas {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return CorridaGuardada{}, false
+}
+
+// historialCorridas es el historial del servidor; main lo reemplaza por el
+// del archivo indicado con -historial
+var historialCorridas historial = &historialArchivo{}
+
+// resultadoClave resume en una línea los resultados principales de un resumen
+func resultadoClave(resumen MensajeWS) string {
+	switch r := resumen.Obj.(type) {
+	case ResumenMPI:
+		return fmt.Sprintf("tiempo total %.2f s, mejor vuelta %.2f s (vuelta %d)", r.TiempoTotal, r.MejorVuelta.Tiempo, r.MejorVuelta.Numero)
+	case ResumenOpenMP:
+		if r.MejorGeneral == nil {
+			return "sin resultados"
+		}
+		return fmt.Sprintf("mejor vuelta %.2f s (Auto %d)", r.MejorGeneral.MejorVuelta, r.MejorGeneral.AutoID)
+	case ResumenClasificacion:
+		return fmt.Sprintf("pole para el Auto %d (%.2f s)", r.Pole.AutoID, r.Pole.Tiempo)
+	}
+	// El resto trae los resultados en la primera línea del texto
+	linea, _, _ := strings.Cut(resumen.Texto, "\n")
+	return linea
+}
+
+// registrarEnHistorial guarda la corrida runID si terminó con un resumen. Las
+// corridas detenidas o con error de validación no se guardan.
+func registrarEnHistorial(runID, accion string, semilla *int64, parametros any, inicio time.Time, g grabacion) error {
+	if g.resumen == nil {
+		return nil
+	}
+	datosParametros, err := json.Marshal(parametros)
+	if err != nil {
+		return err
+	}
+	datosResumen, err := json.Marshal(g.resumen.Obj)
+	if err != nil {
+		return err
+	}
+	return historialCorridas.guardar(CorridaGuardada{
+		ID:         runID,
+		Tipo:       accion,
+		Fecha:      inicio,
+		DuracionMs: time.Since(inicio).Milliseconds(),
+		Semilla:    semilla,
+		Parametros: datosParametros,
+		Resultado:  resultadoClave(*g.resumen),
+		Resumen:    datosResumen,
+	})
+}
+
+// apiHistorialHandler atiende GET /api/historial?n=20 con las últimas
+// corridas y GET /api/historial/{id} con el detalle de una
+func apiHistorialHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar GET"})
+		return
+	}
+	if id := strings.TrimPrefix(r.URL.Path, "/api/historial/"); id != r.URL.Path && id != "" {
+		c, ok := historialCorridas.buscar(id)
+		if !ok {
+			responderJSON(w, http.StatusNotFound, ErrorAPI{Error: fmt.Sprintf("no hay una corrida con id %q en el historial", id)})
+			return
+		}
+		responderJSON(w, http.StatusOK, c)
+		return
+	}
+	n := 20
+	if valor := r.URL.Query().Get("n"); valor != "" {
+		var err error
+		if n, err = strconv.Atoi(valor); err != nil || n < 1 {
+			responderJSON(w, http.StatusBadRequest, ErrorAPI{Error: "n debe ser un entero >= 1"})
+			return
+		}
+	}
+	responderJSON(w, http.StatusOK, historialCorridas.ultimas(n))
+}
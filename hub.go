@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// -------------------- Hub de espectadores --------------------
+
+// hub reparte los mensajes de las simulaciones difundidas a todas las
+// conexiones registradas. Las simulaciones escriben en difusion y una única
+// goroutine los reparte sin bloquearse: si un cliente está saturado el
+// mensaje se omite para ese cliente y la simulación sigue su curso.
+type hub struct {
+	mu       sync.Mutex
+	clientes map[chan MensajeWS]struct{}
+	difusion chan MensajeWS
+}
+
+func nuevoHub() *hub {
+	h := &hub{
+		clientes: map[chan MensajeWS]struct{}{},
+		difusion: make(chan MensajeWS, 256),
+	}
+	go h.repartir()
+	return h
+}
+
+// espectadores es el hub compartido por todas las conexiones WebSocket
+var espectadores = nuevoHub()
+
+// registrar suma el canal de salida de una conexión a la difusión
+func (h *hub) registrar(c chan MensajeWS) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clientes[c] = struct{}{}
+}
+
+// quitar saca el canal de la difusión. Al retornar el hub ya no escribe en
+// c, por lo que la conexión puede cerrarlo sin riesgo.
+func (h *hub) quitar(c chan MensajeWS) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clientes, c)
+}
+
+func (h *hub) repartir() {
+	for msg := range h.difusion {
+		h.mu.Lock()
+		for c := range h.clientes {
+			select {
+			case c <- msg:
+			default:
+				slog.Warn("espectador saturado, se omite un mensaje", "topico", msg.Topico, "run_id", msg.RunID)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
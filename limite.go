@@ -0,0 +1,59 @@
+package main
+
+import "fmt"
+
+// -------------------- Límite de mensajes por simulación --------------------
+
+// maxMensajesPorCorrida es el límite por defecto de mensajes que emite una
+// simulación (ver Config.MaxMensajes). Una corrida mal configurada (pausa
+// mínima y cantidades enormes) podría mandar millones de registros.
+const maxMensajesPorCorrida = 10000
+
+// avisoCadaOmitidos es cada cuántos mensajes omitidos se envía un registro
+// de progreso una vez alcanzado el límite
+const avisoCadaOmitidos = 1000
+
+// limiteMensajes cuenta los mensajes de una corrida y, pasado maximo, deja de
+// reenviar los de cada paso (registros, posiciones...). En su lugar envía
+// un aviso al alcanzar el límite y después uno cada avisoCadaOmitidos, con
+// el último mensaje omitido para que se vea por dónde va la simulación. Los
+// resumen, finalizado y error siempre pasan; el resumen trae los totales
+// reales porque lo arma el runner, y su texto indica cuántos se omitieron.
+type limiteMensajes struct {
+	maximo   int
+	enviados int
+	omitidos int
+}
+
+// filtrar devuelve los mensajes que deben reenviarse en lugar de msg:
+// msg mismo, un aviso de progreso o ninguno
+func (l *limiteMensajes) filtrar(msg MensajeWS) []MensajeWS {
+	switch msg.Tipo {
+	case "resumen", "finalizado", "error":
+		if msg.Tipo == "resumen" && l.omitidos > 0 {
+			msg.Texto += fmt.Sprintf("\n%d mensajes omitidos por el límite de %d por simulación", l.omitidos, l.maximo)
+		}
+		l.enviados++
+		return []MensajeWS{msg}
+	}
+	if l.enviados < l.maximo {
+		l.enviados++
+		return []MensajeWS{msg}
+	}
+	l.omitidos++
+	switch {
+	case l.omitidos == 1:
+		return []MensajeWS{{
+			Tipo:   "registro",
+			Topico: msg.Topico,
+			Texto:  fmt.Sprintf("Límite de %d mensajes alcanzado: se omiten los siguientes y se informa el progreso cada %d", l.maximo, avisoCadaOmitidos),
+		}}
+	case l.omitidos%avisoCadaOmitidos == 0:
+		return []MensajeWS{{
+			Tipo:   "registro",
+			Topico: msg.Topico,
+			Texto:  fmt.Sprintf("%d mensajes omitidos; último: %s", l.omitidos, msg.Texto),
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLimiteMensajes(t *testing.T) {
+	const excedente = 2500
+	l := &limiteMensajes{maximo: maxMensajesPorCorrida}
+	var salida []MensajeWS
+	for i := 1; i <= maxMensajesPorCorrida+excedente; i++ {
+		salida = append(salida, l.filtrar(MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("paso %d", i)})...)
+	}
+	// Pasan los primeros maxMensajesPorCorrida, el aviso del límite y uno
+	// de progreso cada avisoCadaOmitidos omitidos
+	avisos := salida[maxMensajesPorCorrida:]
+	if len(avisos) != 1+excedente/avisoCadaOmitidos {
+		t.Fatalf("%d mensajes después del límite, se esperaban %d: %+v", len(avisos), 1+excedente/avisoCadaOmitidos, avisos)
+	}
+	if ultimo := salida[maxMensajesPorCorrida-1].Texto; ultimo != fmt.Sprintf("paso %d", maxMensajesPorCorrida) {
+		t.Errorf("el último mensaje antes del límite es %q", ultimo)
+	}
+	if !strings.HasPrefix(avisos[0].Texto, fmt.Sprintf("Límite de %d mensajes alcanzado", maxMensajesPorCorrida)) {
+		t.Errorf("aviso del límite: %q", avisos[0].Texto)
+	}
+	for i, aviso := range avisos[1:] {
+		omitidos := (i + 1) * avisoCadaOmitidos
+		esperado := fmt.Sprintf("%d mensajes omitidos; último: paso %d", omitidos, maxMensajesPorCorrida+omitidos)
+		if aviso.Tipo != "registro" || aviso.Topico != "mpi" || aviso.Texto != esperado {
+			t.Errorf("aviso de progreso %+v, se esperaba %q", aviso, esperado)
+		}
+	}
+
+	// resumen, finalizado y error pasan siempre, aun pasado el límite
+	for _, tipo := range []string{"error", "resumen", "finalizado"} {
+		filtrados := l.filtrar(MensajeWS{Tipo: tipo, Topico: "mpi", Texto: tipo})
+		if len(filtrados) != 1 || filtrados[0].Tipo != tipo {
+			t.Fatalf("%s filtrado como %+v", tipo, filtrados)
+		}
+		if tipo == "resumen" && !strings.Contains(filtrados[0].Texto, fmt.Sprintf("%d mensajes omitidos", excedente)) {
+			t.Errorf("el resumen no informa los omitidos: %q", filtrados[0].Texto)
+		}
+	}
+}
+
+func TestCorridaLargaRespetaElLimite(t *testing.T) {
+	if configuracion.MaxMensajes != maxMensajesPorCorrida {
+		t.Fatalf("límite configurado %d, se esperaba el por defecto %d", configuracion.MaxMensajes, maxMensajesPorCorrida)
+	}
+	const sectores, vueltas = 5, 3000
+	var mensajes []MensajeWS
+	recorrerMensajes(context.Background(), func(ctx context.Context, enviar chan MensajeWS) {
+		salida, listo := etiquetarCorrida(nuevoRunID(), enviar)
+		correrMPI(ctx, parametrosMPIPrueba(sectores, vueltas), nil, salida)
+		close(salida)
+		<-listo
+	}, func(msg MensajeWS) {
+		mensajes = append(mensajes, msg)
+	})
+	if permitidos := maxMensajesPorCorrida + 1 + sectores*vueltas/avisoCadaOmitidos + 2; len(mensajes) > permitidos {
+		t.Errorf("llegaron %d mensajes, el límite permite %d", len(mensajes), permitidos)
+	}
+	// El resumen trae los totales reales, no solo lo que se envió
+	resumen := resumenMPI(t, mensajes)
+	if len(resumen.Vueltas) != vueltas || resumen.Estadisticas.Cantidad != sectores*vueltas {
+		t.Errorf("resumen con %d vueltas y %d tiempos, se esperaban %d y %d", len(resumen.Vueltas), resumen.Estadisticas.Cantidad, vueltas, sectores*vueltas)
+	}
+	if ultimo := mensajes[len(mensajes)-1]; ultimo.Tipo != "finalizado" {
+		t.Errorf("el último mensaje es %q, se esperaba finalizado", ultimo.Tipo)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// -------------------- Mensajes agrupados en lotes --------------------
+
+// agruparEnLotes devuelve un canal cuyos mensajes llegan a destino agrupados:
+// los de cada paso (registros, posiciones...) se acumulan y una vez por
+// intervalo se envía un único mensaje "lote" con todos ellos, en orden, en
+// Obj. Así una simulación rápida no manda un frame por evento y el cliente
+// recibe los mismos datos. Los resumen, finalizado y error no se agrupan:
+// antes de reenviarlos se envía el lote pendiente, para no alterar el orden.
+// Al cerrarse el canal devuelto se envía lo pendiente y se cierra listo.
+func agruparEnLotes(intervalo time.Duration, destino chan MensajeWS) (entrada chan MensajeWS, listo <-chan struct{}) {
+	entrada = make(chan MensajeWS)
+	hecho := make(chan struct{})
+	go func() {
+		defer close(hecho)
+		temporizador := time.NewTicker(intervalo)
+		defer temporizador.Stop()
+		var pendientes []MensajeWS
+		vaciar := func() {
+			if len(pendientes) == 0 {
+				return
+			}
+			ultimo := pendientes[len(pendientes)-1]
+			destino <- MensajeWS{
+				Tipo:    "lote",
+				Topico:  ultimo.Topico,
+				Texto:   fmt.Sprintf("%d eventos", len(pendientes)),
+				Obj:     pendientes,
+				RunID:   ultimo.RunID,
+				MsEpoch: ultimo.MsEpoch,
+			}
+			pendientes = nil
+		}
+		for {
+			select {
+			case msg, ok := <-entrada:
+				if !ok {
+					vaciar()
+					return
+				}
+				switch msg.Tipo {
+				case "resumen", "finalizado", "error":
+					vaciar()
+					destino <- msg
+				default:
+					pendientes = append(pendientes, msg)
+				}
+			case <-temporizador.C:
+				vaciar()
+			}
+		}
+	}()
+	return entrada, hecho
+}
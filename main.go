@@ -1,297 +1,2156 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"log/slog"
+	"math"
 	"math/rand"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"formula-sim/protocolo"
+
 	"github.com/gorilla/websocket"
 )
 
-/*
-Simulaciones Fórmula 1 con MPI y OpenMP (Didáctico)
----------------------------------------------------
-MPI -> Simula un anillo de sectores con paso de mensajes entre goroutines usando canales
-OpenMP -> Simula varios autos corriendo vueltas rápidas en paralelo usando goroutines y mutex
-*/
+/*
+Simulaciones Fórmula 1 con MPI y OpenMP (Didáctico)
+---------------------------------------------------
+MPI -> Simula un anillo de sectores con paso de mensajes entre goroutines usando canales
+OpenMP -> Simula varios autos corriendo vueltas rápidas en paralelo usando goroutines y canales
+*/
+
+// -------------------- Configuración WebSocket --------------------
+
+// Permite cualquier origen (útil para pruebas locales)
+var actualizador = websocket.Upgrader{
+	CheckOrigin:  func(r *http.Request) bool { return true },
+	Subprotocols: protocolosSoportados,
+}
+
+// Versiones del formato de mensajes, negociadas como subprotocolo del
+// WebSocket (Sec-WebSocket-Protocol). Un cambio incompatible agrega una
+// versión nueva y las anteriores siguen funcionando para los clientes viejos.
+const protocoloV1 = protocolo.V1
+
+// protocolosSoportados son las versiones que acepta el servidor, en orden de
+// preferencia; un cliente que no pide ninguna recibe protocoloV1
+var protocolosSoportados = []string{protocoloV1}
+
+// negociarProtocolo elige la versión de los mensajes para r. Sin subprotocolo
+// pedido es protocoloV1; si el cliente pide versiones y ninguna es conocida,
+// devuelve error para rechazar la conexión antes del upgrade.
+func negociarProtocolo(r *http.Request) (string, error) {
+	pedidos := websocket.Subprotocols(r)
+	if len(pedidos) == 0 {
+		return protocoloV1, nil
+	}
+	for _, soportado := range protocolosSoportados {
+		if slices.Contains(pedidos, soportado) {
+			return soportado, nil
+		}
+	}
+	return "", fmt.Errorf("versión de protocolo no soportada: %s (usar %s)", strings.Join(pedidos, ", "), strings.Join(protocolosSoportados, ", "))
+}
+
+// Keepalive: el servidor envía un ping cada intervaloPing y da la conexión
+// por muerta si pasa esperaPong sin recibir nada del cliente
+const (
+	intervaloPing = 30 * time.Second
+	esperaPong    = intervaloPing + 10*time.Second
+)
+
+// maxTamanioComando es el mayor comando que se acepta del cliente, en bytes:
+// sobra para autos_config o nombres_sectores en sus máximos. Uno más grande
+// se rechaza con un error y se cierra la conexión sin terminar de leerlo.
+// Los mensajes de más de limiteLecturaWS los corta directamente gorilla, sin
+// leerlos ni avisar, así ningún cliente hace crecer la memoria sin límite.
+const (
+	maxTamanioComando = 64 << 10
+	limiteLecturaWS   = 4 * maxTamanioComando
+)
+
+// errComandoGrande es el error de lectura de un comando de más de maxTamanioComando
+var errComandoGrande = fmt.Errorf("comando demasiado grande (máximo %d bytes)", maxTamanioComando)
+
+// leerComando lee el siguiente mensaje del cliente sin guardar más de
+// maxTamanioComando + 1 bytes
+func leerComando(conn *websocket.Conn) ([]byte, error) {
+	_, lector, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	comando, err := io.ReadAll(io.LimitReader(lector, maxTamanioComando+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(comando) > maxTamanioComando {
+		return nil, errComandoGrande
+	}
+	return comando, nil
+}
+
+// -------------------- Tipo de mensaje simplificado --------------------
+
+// mensajeError arma el mensaje con el que se informa un error al cliente: un
+// comando inválido o una simulación que no puede correr o seguir. Las
+// goroutines de una simulación que fallen deben informarlo así por su canal
+// de mensajes en lugar de solo dejarlo en el log.
+func mensajeError(topico string, err error) MensajeWS {
+	return MensajeWS{Tipo: "error", Topico: topico, Texto: "Error: " + err.Error(), Error: err.Error()}
+}
+
+// nuevoRunID genera un identificador aleatorio corto para una corrida; las
+// conexiones WebSocket usan el mismo formato para su id de correlación
+func nuevoRunID() string {
+	b := make([]byte, 6)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// etiquetarCorrida devuelve un canal cuyos mensajes se reenvían a destino con
+// RunID y MsEpoch completados, respetando el límite de mensajes por corrida
+// (ver limiteMensajes). El finalizado lleva en Obj la duración y los
+// mensajes de la corrida (FinCorrida). Al cerrarse, la corrida queda guardada en
+// grabaciones para poder reproducirla. El llamador debe cerrar el canal devuelto cuando
+// termina de enviar y esperar a listo antes de dar por finalizada la corrida;
+// por listo se recibe la grabación completa.
+//
+// El canal no tiene buffer: el mensaje se recibe en el mismo momento en que la
+// simulación lo envía, así MsEpoch mide cuándo ocurrió y no cuándo se reenvió.
+func etiquetarCorrida(runID string, destino chan MensajeWS) (entrada chan MensajeWS, listo <-chan grabacion) {
+	entrada = make(chan MensajeWS)
+	hecho := make(chan grabacion, 1)
+	inicio := time.Now()
+	go func() {
+		defer close(hecho)
+		var g grabacion
+		limite := limiteMensajes{maximo: configuracion.MaxMensajes}
+		eventos := 0
+		for recibido := range entrada {
+			for _, msg := range limite.filtrar(recibido) {
+				eventos++
+				msg.RunID = runID
+				msg.MsEpoch = time.Since(inicio).Milliseconds()
+				if msg.Tipo == "finalizado" {
+					// También el de una reproducción, que trae el de la corrida original
+					msg.Obj = FinCorrida{RunID: runID, DuracionMs: msg.MsEpoch, Eventos: eventos}
+				}
+				if msg.Tipo == "resumen" {
+					g.resumen = &msg
+				}
+				if len(g.mensajes) < maxMensajesGrabados {
+					g.mensajes = append(g.mensajes, msg)
+				} else {
+					g.truncada = true
+				}
+				destino <- msg
+			}
+		}
+		if len(g.mensajes) > 0 {
+			guardarGrabacion(runID, g)
+		}
+		hecho <- g
+	}()
+	return entrada, hecho
+}
+
+// -------------------- Tiempos aleatorios --------------------
+
+// Rangos de tiempo por defecto (en segundos) de cada simulación
+const (
+	tiempoMinSector = 12.00
+	tiempoMaxSector = 35.00
+	tiempoMinVuelta = 75.00
+	tiempoMaxVuelta = 95.99
+)
+
+// tiempoAleatorio devuelve un tiempo con precisión de centésimas dentro de [min, max).
+// Trabaja en centésimas enteras para que el resultado no arrastre ruido de coma flotante.
+func tiempoAleatorio(r *rand.Rand, min, max float64) float64 {
+	desde := math.Ceil(min*100 - 1e-9)
+	hasta := math.Ceil(max*100 - 1e-9) // exclusivo
+	centesimas := int(hasta - desde)
+	if centesimas < 1 {
+		return min
+	}
+	return (desde + float64(r.Intn(centesimas))) / 100.0
+}
+
+// Distribuciones de los tiempos sorteados (parámetro distribucion)
+const (
+	distribucionUniforme   = "uniforme"   // todos los tiempos del rango con la misma probabilidad
+	distribucionNormal     = "normal"     // campana centrada en el medio del rango
+	distribucionTriangular = "triangular" // más probable el medio, nunca fuera del rango
+)
+
+// validarDistribucion acepta las distribuciones conocidas y "" (uniforme)
+func validarDistribucion(dist string) error {
+	switch dist {
+	case "", distribucionUniforme, distribucionNormal, distribucionTriangular:
+		return nil
+	}
+	return fmt.Errorf("distribucion debe ser %s, %s o %s", distribucionUniforme, distribucionNormal, distribucionTriangular)
+}
+
+// muestrear sortea un tiempo con precisión de centésimas dentro de [min, max]
+// según dist. La uniforme es tiempoAleatorio, así una semilla da los mismos
+// tiempos que antes de existir el parámetro. La normal tiene media en el
+// medio del rango y desvío de un sexto del rango (casi todo cae dentro); lo
+// que queda afuera se recorta a los extremos. La triangular es simétrica,
+// con el máximo en el medio del rango.
+func muestrear(dist string, min, max float64, r *rand.Rand) float64 {
+	medio, ancho := (min+max)/2, max-min
+	var t float64
+	switch dist {
+	case distribucionNormal:
+		t = medio + r.NormFloat64()*ancho/6
+	case distribucionTriangular:
+		u := r.Float64()
+		if u < 0.5 {
+			t = min + ancho*math.Sqrt(u/2)
+		} else {
+			t = max - ancho*math.Sqrt((1-u)/2)
+		}
+	default:
+		return tiempoAleatorio(r, min, max)
+	}
+	return redondear(math.Min(math.Max(t, min), max))
+}
+
+// resolverSemilla devuelve la semilla pedida por el cliente o, si no indicó
+// ninguna, una basada en la hora actual.
+func resolverSemilla(semilla *int64) int64 {
+	if semilla != nil {
+		return *semilla
+	}
+	return time.Now().UnixNano()
+}
+
+// redondear lleva un tiempo calculado a centésimas de segundo
+func redondear(t float64) float64 {
+	return math.Round(t*100) / 100
+}
+
+// Formatos de los tiempos en el Texto de los registros (parámetro formato_tiempo)
+const (
+	formatoSegundos     = "s"         // "62.50 s"
+	formatoMilisegundos = "ms"        // "62500 ms"
+	formatoMinutos      = "mm:ss.mmm" // "1:02.500"
+)
+
+// validarFormatoTiempo acepta los formatos conocidos y "" (segundos)
+func validarFormatoTiempo(formato string) error {
+	switch formato {
+	case "", formatoSegundos, formatoMilisegundos, formatoMinutos:
+		return nil
+	}
+	return fmt.Errorf("formato_tiempo debe ser %s, %s o %s", formatoSegundos, formatoMilisegundos, formatoMinutos)
+}
+
+// formatearTiempo escribe seg segundos en el formato indicado; un formato
+// vacío o desconocido usa segundos con centésimas
+func formatearTiempo(seg float64, formato string) string {
+	switch formato {
+	case formatoMilisegundos:
+		return fmt.Sprintf("%d ms", int64(math.Round(seg*1000)))
+	case formatoMinutos:
+		// El signo sale del valor redondeado, así -0.0001 no queda "-0:00.000"
+		signo := ""
+		ms := int64(math.Round(seg * 1000))
+		if ms < 0 {
+			signo, ms = "-", -ms
+		}
+		return fmt.Sprintf("%s%d:%02d.%03d", signo, ms/60000, ms/1000%60, ms%1000)
+	}
+	return fmt.Sprintf("%.2f s", seg)
+}
+
+// calcularEstadisticas resume tiempos; sin tiempos devuelve todo en cero
+func calcularEstadisticas(tiempos []float64) Estadisticas {
+	if len(tiempos) == 0 {
+		return Estadisticas{}
+	}
+	e := Estadisticas{Cantidad: len(tiempos), Minimo: tiempos[0], Maximo: tiempos[0]}
+	suma := 0.0
+	for _, t := range tiempos {
+		e.Minimo = math.Min(e.Minimo, t)
+		e.Maximo = math.Max(e.Maximo, t)
+		suma += t
+	}
+	media := suma / float64(len(tiempos))
+	cuadrados := 0.0
+	for _, t := range tiempos {
+		cuadrados += (t - media) * (t - media)
+	}
+	e.Media = redondear(media)
+	e.Desvio = redondear(math.Sqrt(cuadrados / float64(len(tiempos))))
+	return e
+}
+
+// validarRango comprueba que el rango de tiempos configurado sea utilizable
+func validarRango(min, max float64) error {
+	if min <= 0 || max <= 0 {
+		return fmt.Errorf("tiempo_min y tiempo_max deben ser > 0")
+	}
+	if min >= max {
+		return fmt.Errorf("tiempo_min debe ser menor que tiempo_max")
+	}
+	return nil
+}
+
+// -------------------- Clima --------------------
+
+// multiplicadoresClima escala los tiempos generados según el estado de la pista.
+// Para agregar una condición nueva basta con sumarla al mapa.
+var multiplicadoresClima = map[string]float64{
+	"seco":   1.0,
+	"humedo": 1.15,
+	"lluvia": 1.35,
+}
+
+// climaPorDefecto se usa cuando el cliente no indica clima o indica uno desconocido
+const climaPorDefecto = "seco"
+
+// resolverClima devuelve el clima a usar y su multiplicador. ok es false si
+// se pidió un clima desconocido y se recurrió al valor por defecto.
+func resolverClima(clima string) (nombre string, multiplicador float64, ok bool) {
+	if clima == "" {
+		return climaPorDefecto, multiplicadoresClima[climaPorDefecto], true
+	}
+	if m, existe := multiplicadoresClima[clima]; existe {
+		return clima, m, true
+	}
+	return climaPorDefecto, multiplicadoresClima[climaPorDefecto], false
+}
+
+// anunciarClima resuelve el clima pedido e informa al cliente cuál quedó activo
+func anunciarClima(clima, topico string, enviar chan MensajeWS) (string, float64) {
+	nombre, multiplicador, ok := resolverClima(clima)
+	if !ok {
+		enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Advertencia: clima %q desconocido, se usa %q", clima, nombre)}
+	}
+	enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Clima: %s (x%.2f)", nombre, multiplicador)}
+	return nombre, multiplicador
+}
+
+// esperar duerme el tiempo indicado salvo que el contexto se cancele antes.
+// Devuelve false si la simulación fue detenida durante la espera.
+func esperar(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	temporizador := time.NewTimer(d)
+	defer temporizador.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-temporizador.C:
+		return true
+	}
+}
+
+// compuerta permite pausar y reanudar una simulación entre pasos. El canal
+// abierta está cerrado mientras la simulación puede avanzar.
+type compuerta struct {
+	mu      sync.Mutex
+	abierta chan struct{}
+}
+
+func nuevaCompuerta() *compuerta {
+	c := &compuerta{abierta: make(chan struct{})}
+	close(c.abierta)
+	return c
+}
+
+// pausar hace que el próximo pasar bloquee hasta reanudar. Es idempotente.
+func (c *compuerta) pausar() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.abierta:
+		c.abierta = make(chan struct{})
+	default: // ya estaba en pausa
+	}
+}
+
+// reanudar libera a quien esté esperando en pasar. Es idempotente.
+func (c *compuerta) reanudar() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.abierta: // no estaba en pausa
+	default:
+		close(c.abierta)
+	}
+}
+
+// pasar bloquea mientras la compuerta esté en pausa. Devuelve false si ctx se
+// cancela durante la espera. Una compuerta nil nunca bloquea.
+func (c *compuerta) pasar(ctx context.Context) bool {
+	if c == nil {
+		return ctx.Err() == nil
+	}
+	c.mu.Lock()
+	abierta := c.abierta
+	c.mu.Unlock()
+	select {
+	case <-abierta:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// -------------------- MPI (anillo de sectores) --------------------
+
+// ParametrosMPI agrupa la configuración de una simulación MPI
+type ParametrosMPI struct {
+	Sectores  int           `json:"sectores"`
+	Vueltas   int           `json:"vueltas"`
+	TiempoMin float64       `json:"tiempo_min"` // tiempo mínimo por sector (s)
+	TiempoMax float64       `json:"tiempo_max"` // tiempo máximo por sector (s)
+	Retardo   time.Duration `json:"-"`          // pausa entre sectores (0 = sin pausa)
+
+	// Degradacion son los segundos que pierde cada sector por cada vuelta de
+	// uso del neumático: en la vuelta v se suman (v-1)*Degradacion.
+	Degradacion float64 `json:"degradacion"`
+
+	// Semilla fija la secuencia de tiempos generados; nil usa la hora actual
+	Semilla *int64 `json:"semilla,omitempty"`
+
+	Clima string `json:"clima"` // "seco", "humedo" o "lluvia" (ver multiplicadoresClima)
+
+	// NombresSectores nombra cada sector ("Recta principal", "Curva 3"...);
+	// debe tener un nombre por sector o se usan los nombres numéricos.
+	NombresSectores []string `json:"nombres_sectores,omitempty"`
+
+	// Pipeline corre cada sector como una etapa (ver correrPipelineMPI)
+	Pipeline bool `json:"pipeline"`
+
+	// BanderaRojaUmbral corta la vuelta en el primer sector que tarde más
+	// (0 = sin bandera roja); ContinuarTrasBandera sigue con la vuelta
+	// siguiente en lugar de terminar la sesión.
+	BanderaRojaUmbral    float64 `json:"bandera_roja_umbral"`
+	ContinuarTrasBandera bool    `json:"continuar_tras_bandera"`
+
+	// FormatoTiempo es el formato de los tiempos en el texto de los registros
+	FormatoTiempo string `json:"formato_tiempo"`
+
+	// Distribucion es cómo se sortea cada tiempo de sector dentro de su rango
+	// (ver muestrear)
+	Distribucion string `json:"distribucion"`
+
+	// Circuito elige un perfil de circuitos: su cantidad de sectores
+	// reemplaza a Sectores y cada sector tiene su propio rango de tiempos.
+	Circuito string `json:"circuito,omitempty"`
+
+	// Vista es cómo se informa cada sector: vistaFilas (un registro por
+	// sector de cada vuelta) o vistaGrilla (la columna del sector con todas
+	// las vueltas, ver corridaMPI.columnaSector)
+	Vista string `json:"vista"`
+}
+
+// Vistas de los tiempos de sector MPI (parámetro vista)
+const (
+	vistaFilas  = "filas"
+	vistaGrilla = "grilla"
+)
+
+// parametrosMPIPorDefecto devuelve la configuración usada cuando el cliente no indica valores
+func parametrosMPIPorDefecto() ParametrosMPI {
+	return ParametrosMPI{
+		Sectores:  configuracion.Sectores,
+		Vueltas:   configuracion.VueltasMPI,
+		TiempoMin: tiempoMinSector,
+		TiempoMax: tiempoMaxSector,
+		Retardo:   milisegundos(configuracion.DelaySectorMs),
+
+		FormatoTiempo: formatoSegundos,
+		Distribucion:  distribucionUniforme,
+		Vista:         vistaFilas,
+	}
+}
+
+// validar comprueba que los parámetros permitan correr la simulación
+func (p ParametrosMPI) validar() error {
+	if p.Sectores < 1 {
+		return fmt.Errorf("sectores debe ser >= 1")
+	}
+	if p.Sectores > configuracion.MaxSectores {
+		return fmt.Errorf("sectores debe ser <= %d", configuracion.MaxSectores)
+	}
+	// Pedir 0 vueltas casi seguro es un error del cliente: se rechaza igual
+	// que 0 sectores en lugar de correr una vuelta sin avisar
+	if p.Vueltas < 1 {
+		return fmt.Errorf("vueltas debe ser >= 1")
+	}
+	if p.Vueltas > configuracion.MaxVueltas {
+		return fmt.Errorf("vueltas debe ser <= %d", configuracion.MaxVueltas)
+	}
+	if p.Degradacion < 0 {
+		return fmt.Errorf("degradacion debe ser >= 0")
+	}
+	if p.BanderaRojaUmbral < 0 {
+		return fmt.Errorf("bandera_roja_umbral debe ser >= 0")
+	}
+	if err := validarFormatoTiempo(p.FormatoTiempo); err != nil {
+		return err
+	}
+	if err := validarDistribucion(p.Distribucion); err != nil {
+		return err
+	}
+	if err := validarCircuito(p.Circuito); err != nil {
+		return err
+	}
+	if p.Vista != vistaFilas && p.Vista != vistaGrilla && p.Vista != "" {
+		return fmt.Errorf("vista debe ser %s o %s", vistaFilas, vistaGrilla)
+	}
+	if p.Retardo < 0 {
+		return fmt.Errorf("delay_ms debe ser >= 0")
+	}
+	return validarRango(p.TiempoMin, p.TiempoMax)
+}
+
+// Colores de los tiempos de sector (ver corridaMPI.color)
+const (
+	colorMorado   = "morado"   // el mejor de la sesión en ese sector
+	colorVerde    = "verde"    // mejor personal: más rápido que en las vueltas anteriores
+	colorAmarillo = "amarillo" // ni uno ni otro
+)
+
+// mejoresSectores devuelve, para cada sector, su tiempo más rápido entre todas
+// las vueltas de tiempos (tiempos[v][s]). Ante un empate queda la vuelta anterior.
+func mejoresSectores(tiempos [][]float64, nombres []string) []TiempoSector {
+	var mejores []TiempoSector
+	for v, vuelta := range tiempos {
+		if len(vuelta) == 0 {
+			continue // vuelta cortada por bandera roja
+		}
+		if mejores == nil {
+			mejores = make([]TiempoSector, len(vuelta))
+			for s, tiempo := range vuelta {
+				mejores[s] = TiempoSector{Sector: s + 1, Nombre: nombres[s], Vuelta: v + 1, Tiempo: tiempo}
+			}
+			continue
+		}
+		for s, tiempo := range vuelta {
+			if tiempo < mejores[s].Tiempo {
+				mejores[s].Vuelta, mejores[s].Tiempo = v+1, tiempo
+			}
+		}
+	}
+	return mejores
+}
+
+// resolverNombresSectores devuelve el nombre de cada sector. Si p.NombresSectores
+// no tiene uno por sector avisa por enviar y usa "Sector 1", "Sector 2"...
+func resolverNombresSectores(p ParametrosMPI, enviar chan MensajeWS) []string {
+	if len(p.NombresSectores) == p.Sectores {
+		return p.NombresSectores
+	}
+	if len(p.NombresSectores) > 0 {
+		enviar <- MensajeWS{
+			Tipo:   "registro",
+			Topico: "mpi",
+			Texto:  fmt.Sprintf("Aviso: se recibieron %d nombres para %d sectores, se usan nombres numéricos", len(p.NombresSectores), p.Sectores),
+		}
+	}
+	nombres := make([]string, p.Sectores)
+	for s := range nombres {
+		nombres[s] = fmt.Sprintf("Sector %d", s+1)
+	}
+	return nombres
+}
+
+// generarTiemposMPI sortea el tiempo de cada sector de cada vuelta
+// (tiempos[v][s]) en el orden en que el auto los recorre, así una misma
+// semilla da los mismos tiempos con y sin pipeline.
+func generarTiemposMPI(aleatorio *rand.Rand, p ParametrosMPI, vueltas int, multiplicador float64) [][]float64 {
+	tiempos := make([][]float64, vueltas)
+	for v := range tiempos {
+		degradacion := float64(v) * p.Degradacion
+		tiempos[v] = make([]float64, p.Sectores)
+		for s := range tiempos[v] {
+			minimo, maximo := p.rangoSector(s + 1)
+			tiempos[v][s] = redondear(muestrear(p.Distribucion, minimo, maximo, aleatorio)*multiplicador + degradacion)
+		}
+	}
+	return tiempos
+}
+
+// corridaMPI son los tiempos sorteados de una corrida MPI antes de largar y
+// lo necesario para informar cada paso, igual en modo secuencial y en pipeline
+type corridaMPI struct {
+	p        ParametrosMPI
+	tiempos  [][]float64 // tiempos[v][s]: sector s+1 de la vuelta v+1
+	banderas []int       // banderas[v]: sector con bandera roja en la vuelta v+1 (0 = ninguno)
+	nombres  []string
+	mejores  *mejoresSesion // mejor tiempo de cada sector hasta el momento
+}
+
+// mejoresSesion lleva el mejor tiempo de cada sector de la sesión a medida
+// que se completan. Todos los pasos por sector pasan por acá (en pipeline,
+// desde la goroutine de cada etapa), así el morado solo depende de lo que
+// ya se corrió y no de las vueltas que faltan.
+type mejoresSesion struct {
+	mu      sync.Mutex
+	tiempos []float64 // tiempos[s]: mejor del sector s+1 (0 = todavía ninguno)
+}
+
+// completar registra tiempo en el sector s (desde 1) e indica si es el nuevo
+// mejor de la sesión; ante un empate lo conserva la vuelta anterior
+func (m *mejoresSesion) completar(s int, tiempo float64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mejor := m.tiempos[s-1]; mejor != 0 && tiempo >= mejor {
+		return false
+	}
+	m.tiempos[s-1] = tiempo
+	return true
+}
+
+// nuevaCorridaMPI marca en qué sector de cada vuelta hay bandera roja (el
+// primero cuyo tiempo supera p.BanderaRojaUmbral; sin umbral, ninguno).
+func nuevaCorridaMPI(p ParametrosMPI, tiempos [][]float64, nombres []string) corridaMPI {
+	c := corridaMPI{
+		p: p, tiempos: tiempos, banderas: make([]int, len(tiempos)), nombres: nombres,
+		mejores: &mejoresSesion{tiempos: make([]float64, p.Sectores)},
+	}
+	if p.BanderaRojaUmbral > 0 {
+		for v, vuelta := range tiempos {
+			for s, tiempo := range vuelta {
+				if tiempo > p.BanderaRojaUmbral {
+					c.banderas[v] = s + 1
+					break
+				}
+			}
+		}
+	}
+	return c
+}
+
+// corte es la vuelta en la que una bandera roja termina la sesión, o 0 si
+// se corren todas (sin banderas o con p.ContinuarTrasBandera)
+func (c corridaMPI) corte() int {
+	if c.p.ContinuarTrasBandera {
+		return 0
+	}
+	for v, sector := range c.banderas {
+		if sector != 0 {
+			return v + 1
+		}
+	}
+	return 0
+}
+
+// tiempo escribe seg en el formato de tiempos de la corrida
+func (c corridaMPI) tiempo(seg float64) string {
+	return formatearTiempo(seg, c.p.FormatoTiempo)
+}
+
+// degradacion son los segundos que pierde cada sector en la vuelta v
+func (c corridaMPI) degradacion(v int) float64 {
+	return float64(v-1) * c.p.Degradacion
+}
+
+// mejorAnterior devuelve el mejor tiempo del sector s en las vueltas
+// anteriores a v (desde 1) completadas, las cortadas por bandera roja no
+// cuentan; ok es false si no hay ninguna
+func (c corridaMPI) mejorAnterior(v, s int) (mejor float64, ok bool) {
+	for anterior := 0; anterior < v-1; anterior++ {
+		if c.banderas[anterior] != 0 {
+			continue
+		}
+		if tiempo := c.tiempos[anterior][s-1]; !ok || tiempo < mejor {
+			mejor, ok = tiempo, true
+		}
+	}
+	return mejor, ok
+}
+
+// esMejorPersonal indica si el sector s de la vuelta v (desde 1) es más
+// rápido que ese sector en todas las vueltas anteriores completadas
+func (c corridaMPI) esMejorPersonal(v, s int) bool {
+	mejor, ok := c.mejorAnterior(v, s)
+	return ok && c.tiempos[v-1][s-1] < mejor
+}
+
+// color clasifica el sector s de la vuelta v: morado si al completarse fue
+// el mejor tiempo de la sesión en ese sector hasta ese momento (ver
+// mejoresSesion), verde si mejora las vueltas anteriores completas o es la
+// primera vuelta completa, y amarillo si no. Un sector de una vuelta cortada
+// por bandera roja puede quedar como mejor de la sesión sin contar como
+// mejor personal, por eso una vuelta posterior puede ser verde sin ser morada.
+func (c corridaMPI) color(v, s int, morado bool) string {
+	if morado {
+		return colorMorado
+	}
+	primera := true
+	for anterior := 0; anterior < v-1; anterior++ {
+		if c.banderas[anterior] == 0 {
+			primera = false
+			break
+		}
+	}
+	if primera || c.esMejorPersonal(v, s) {
+		return colorVerde
+	}
+	return colorAmarillo
+}
+
+// registroSector completa el sector s de la vuelta v en los mejores de la
+// sesión y arma su registro, con la diferencia respecto del mejor tiempo del
+// sector en las vueltas anteriores. Se llama una vez por paso, en el orden en
+// que se completan los sectores.
+func (c corridaMPI) registroSector(v, s int) MensajeWS {
+	tiempo, nombre, degradacion := c.tiempos[v-1][s-1], c.nombres[s-1], c.degradacion(v)
+	morado := c.mejores.completar(s, tiempo)
+	texto := fmt.Sprintf("%s recibió tiempo %s (vuelta %d)", nombre, c.tiempo(tiempo), v)
+	if degradacion > 0 {
+		texto = fmt.Sprintf("%s recibió tiempo %s (vuelta %d, +%s por degradación)", nombre, c.tiempo(tiempo), v, c.tiempo(degradacion))
+	}
+	sector := TiempoSector{Sector: s, Nombre: nombre, Vuelta: v, Tiempo: tiempo, MejorPersonal: c.esMejorPersonal(v, s), Color: c.color(v, s, morado)}
+	if mejor, ok := c.mejorAnterior(v, s); ok {
+		sector.DiferenciaMejor = redondear(tiempo - mejor)
+		if sector.MejorPersonal {
+			texto += fmt.Sprintf(" (%s, mejor personal del sector)", c.tiempo(sector.DiferenciaMejor))
+		} else {
+			texto += fmt.Sprintf(" (+%s respecto de su mejor)", c.tiempo(sector.DiferenciaMejor))
+		}
+	}
+	return MensajeWS{Tipo: "registro", Topico: "mpi", Texto: texto, Obj: sector}
+}
+
+// pasoSector arma el mensaje del paso por el sector s de la vuelta v según
+// la vista de la corrida
+func (c corridaMPI) pasoSector(v, s int) MensajeWS {
+	if c.p.Vista == vistaGrilla {
+		return c.columnaSector(v, s)
+	}
+	return c.registroSector(v, s)
+}
+
+// columnaSector arma el mensaje "grilla" del sector s al completarse en la
+// vuelta v: su tiempo en cada vuelta hasta v, para armar una torre de tiempos
+// por columnas. Como los tiempos se sortean antes de largar no hace falta
+// acumularlos; las vueltas cortadas por bandera roja antes de s quedan en 0.
+func (c corridaMPI) columnaSector(v, s int) MensajeWS {
+	columna := ColumnaSector{Sector: s, Nombre: c.nombres[s-1], Tiempos: make([]float64, v)}
+	textos := make([]string, v)
+	for w := 1; w <= v; w++ {
+		textos[w-1] = "-"
+		if bandera := c.banderas[w-1]; bandera == 0 || bandera > s {
+			columna.Tiempos[w-1] = c.tiempos[w-1][s-1]
+			textos[w-1] = c.tiempo(columna.Tiempos[w-1])
+		}
+	}
+	columna.Ultimo = c.registroSector(v, s).Obj.(TiempoSector)
+	return MensajeWS{
+		Tipo:   "grilla",
+		Topico: "mpi",
+		Texto:  fmt.Sprintf("%s: %s", columna.Nombre, strings.Join(textos, " | ")),
+		Obj:    columna,
+	}
+}
+
+// registroBandera arma el aviso de bandera roja de la vuelta v
+func (c corridaMPI) registroBandera(v int) MensajeWS {
+	s := c.banderas[v-1]
+	tiempo := c.tiempos[v-1][s-1]
+	return MensajeWS{
+		Tipo:   "registro",
+		Topico: "mpi",
+		Texto:  fmt.Sprintf("Bandera roja en sector %d (%s, vuelta %d: %s supera %s)", s, c.nombres[s-1], v, c.tiempo(tiempo), c.tiempo(c.p.BanderaRojaUmbral)),
+		Obj:    TiempoSector{Sector: s, Nombre: c.nombres[s-1], Vuelta: v, Tiempo: tiempo},
+	}
+}
+
+// tiemposCorridos devuelve los tiempos de las primeras vueltas; las cortadas
+// por bandera roja quedan vacías
+func (c corridaMPI) tiemposCorridos(vueltas int) [][]float64 {
+	tiempos := make([][]float64, vueltas)
+	for v := range tiempos {
+		tiempos[v] = []float64{}
+		if c.banderas[v] == 0 {
+			tiempos[v] = c.tiempos[v]
+		}
+	}
+	return tiempos
+}
+
+// cerrarVueltaMPI suma la vuelta v (ya recorrida) al resumen e informa su
+// tiempo, si es la mejor vuelta y el tiempo acumulado de carrera
+func cerrarVueltaMPI(resumen *ResumenMPI, c corridaMPI, v int, enviar chan MensajeWS) {
+	totalVuelta := 0.0
+	for _, tiempo := range c.tiempos[v-1] {
+		totalVuelta += tiempo
+	}
+	resumen.TiempoTotal += totalVuelta
+	resumen.DegradacionTotal += c.degradacion(v) * float64(resumen.Sectores)
+
+	totalVuelta = redondear(totalVuelta)
+	primera := len(resumen.Vueltas) == 0
+	resumen.Vueltas = append(resumen.Vueltas, VueltaMPI{Numero: v, Tiempo: totalVuelta})
+	acumulado := AcumuladoMPI{Vuelta: v, Acumulado: redondear(resumen.TiempoTotal)}
+	if !primera {
+		acumulado.Diferencia = redondear(totalVuelta - resumen.MejorVuelta.Tiempo)
+	}
+	if primera || totalVuelta < resumen.MejorVuelta.Tiempo {
+		resumen.MejorVuelta = VueltaMPI{Numero: v, Tiempo: totalVuelta}
+		enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("Vuelta %d: %s (mejor vuelta)", v, c.tiempo(totalVuelta))}
+	} else {
+		enviar <- MensajeWS{
+			Tipo:   "registro",
+			Topico: "mpi",
+			Texto:  fmt.Sprintf("Vuelta %d: %s (+%s respecto a la mejor vuelta)", v, c.tiempo(totalVuelta), c.tiempo(totalVuelta-resumen.MejorVuelta.Tiempo)),
+		}
+	}
+	texto := fmt.Sprintf("Tiempo acumulado tras la vuelta %d: %s", v, c.tiempo(acumulado.Acumulado))
+	switch {
+	case primera:
+	case acumulado.Diferencia < 0:
+		texto += fmt.Sprintf(" (la vuelta mejoró la mejor anterior por %s)", c.tiempo(-acumulado.Diferencia))
+	default:
+		texto += fmt.Sprintf(" (la vuelta quedó a %s de la mejor anterior)", c.tiempo(acumulado.Diferencia))
+	}
+	enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: texto, Obj: acumulado}
+}
+
+// correrMPI simula un auto pasando por sectores de manera secuencial, o
+// como un pipeline de sectores si p.Pipeline (ver correrPipelineMPI).
+// Se detiene antes de tiempo si se cancela ctx (comando "detener") y entre
+// sectores respeta la compuerta de pausa (comandos "pausar"/"reanudar").
+// Con menos de una vuelta no corre: informa el error de validación, no
+// redondea a una vuelta.
+//
+// Una bandera roja corta la vuelta en el sector donde ocurre: la vuelta no
+// cuenta para los resultados y, salvo p.ContinuarTrasBandera, la sesión
+// termina ahí.
+func correrMPI(ctx context.Context, p ParametrosMPI, pausa *compuerta, enviar chan MensajeWS) {
+	p = p.conCircuito()
+	sectores, vueltas := p.Sectores, p.Vueltas
+	if err := p.validar(); err != nil {
+		enviar <- mensajeError("mpi", err)
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi"}
+		return
+	}
+
+	modo := ""
+	if p.Circuito != "" {
+		modo = " en " + p.Circuito
+	}
+	if p.Pipeline {
+		modo += " en pipeline"
+	}
+	enviar <- MensajeWS{
+		Tipo:   "registro",
+		Topico: "mpi",
+		Texto:  fmt.Sprintf("Iniciando MPI: %d sectores, %d vueltas%s", sectores, vueltas, modo),
+	}
+	clima, multiplicador := anunciarClima(p.Clima, "mpi", enviar)
+	nombres := resolverNombresSectores(p, enviar)
+
+	aleatorio := rand.New(rand.NewSource(resolverSemilla(p.Semilla)))
+	corrida := nuevaCorridaMPI(p, generarTiemposMPI(aleatorio, p, vueltas, multiplicador), nombres)
+	resumen := ResumenMPI{Sectores: sectores, Clima: clima, Circuito: p.Circuito}
+	corridas := vueltas
+	corte := corrida.corte()
+	if corte > 0 {
+		corridas = corte
+	}
+	if p.Pipeline {
+		resumen.Etapas = correrPipelineMPI(ctx, corrida, corridas, pausa, enviar, func(v int) {
+			cerrarVueltaMPI(&resumen, corrida, v, enviar)
+		})
+		if ctx.Err() != nil {
+			enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi", Texto: "MPI detenido"}
+			return
+		}
+		for _, e := range resumen.Etapas {
+			enviar <- MensajeWS{
+				Tipo:   "registro",
+				Topico: "mpi",
+				Texto:  fmt.Sprintf("Etapa %s: %d vueltas en %.0f ms (%.2f vueltas/s)", e.Nombre, e.Vueltas, e.DuracionMs, e.VueltasPorSegundo),
+				Obj:    e,
+			}
+		}
+	} else {
+	vueltas:
+		for v := 1; v <= corridas; v++ {
+			enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("=== Vuelta %d ===", v)}
+			for s := 1; s <= sectores; s++ {
+				if corrida.banderas[v-1] == s {
+					enviar <- corrida.registroBandera(v)
+					continue vueltas
+				}
+				enviar <- corrida.pasoSector(v, s)
+				// simulación de paso por sector
+				if !esperar(ctx, p.Retardo) || !pausa.pasar(ctx) {
+					enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi", Texto: "MPI detenido"}
+					return
+				}
+				informarProgreso(ctx, v, s)
+			}
+			cerrarVueltaMPI(&resumen, corrida, v, enviar)
+		}
+	}
+
+	if corte > 0 {
+		enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("Sesión terminada por bandera roja en la vuelta %d", corte)}
+	}
+	for _, sector := range corrida.banderas[:corridas] {
+		if sector != 0 {
+			resumen.BanderasRojas++
+		}
+	}
+	resumen.Tiempos = corrida.tiemposCorridos(corridas)
+
+	for i := range resumen.Vueltas {
+		resumen.Vueltas[i].Diferencia = redondear(resumen.Vueltas[i].Tiempo - resumen.MejorVuelta.Tiempo)
+	}
+	resumen.MejoresSectores = mejoresSectores(resumen.Tiempos, nombres)
+	for _, m := range resumen.MejoresSectores {
+		resumen.VueltaIdeal += m.Tiempo
+	}
+	resumen.VueltaIdeal = redondear(resumen.VueltaIdeal)
+	if len(resumen.MejoresSectores) > 0 {
+		enviar <- MensajeWS{
+			Tipo:   "registro",
+			Topico: "mpi",
+			Texto:  fmt.Sprintf("Vuelta ideal: %s (%s menos que la mejor vuelta)", corrida.tiempo(resumen.VueltaIdeal), corrida.tiempo(resumen.MejorVuelta.Tiempo-resumen.VueltaIdeal)),
+		}
+	}
+	resumen.TiempoTotal = redondear(resumen.TiempoTotal)
+	resumen.DegradacionTotal = redondear(resumen.DegradacionTotal)
+	var todos []float64
+	for _, vuelta := range resumen.Tiempos {
+		todos = append(todos, vuelta...)
+	}
+	resumen.Estadisticas = calcularEstadisticas(todos)
+	texto := fmt.Sprintf("Resultados MPI: tiempo total %.2f s en %d vueltas", resumen.TiempoTotal, len(resumen.Vueltas))
+	if resumen.BanderasRojas > 0 {
+		texto += fmt.Sprintf(", %d banderas rojas", resumen.BanderasRojas)
+	}
+	enviar <- MensajeWS{
+		Tipo:   "resumen",
+		Topico: "mpi",
+		Texto:  texto,
+		Obj:    resumen,
+	}
+	enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi", Texto: "MPI finalizado"}
+}
+
+// -------------------- OpenMP (vueltas rápidas) --------------------
+
+// armarStints parte las vueltas de un auto (tiempos[v-1]) en stints: cada
+// parada en boxes cierra uno y la siguiente vuelta abre otro. La mejor vuelta
+// de cada stint sigue el mismo criterio que la mejor vuelta del auto.
+func armarStints(p ParametrosOpenMP, safetyCar []bool, tiempos []float64) []Stint {
+	var stints []Stint
+	desde := 1
+	for v := 1; v <= len(tiempos); v++ {
+		enBoxes := p.PitCada > 0 && v%p.PitCada == 0
+		if !enBoxes && v < len(tiempos) {
+			continue
+		}
+		stint := Stint{DesdeVuelta: desde, HastaVuelta: v}
+		suma := 0.0
+		for w := desde; w <= v; w++ {
+			tiempo := tiempos[w-1]
+			suma += tiempo
+			competitiva := !(p.PitCada > 0 && w%p.PitCada == 0) && !safetyCar[w]
+			if competitiva && (stint.Mejor == 0 || tiempo < stint.Mejor) {
+				stint.Mejor = tiempo
+			}
+		}
+		stint.Promedio = redondear(suma / float64(v-desde+1))
+		stints = append(stints, stint)
+		desde = v + 1
+	}
+	return stints
+}
+
+// vueltaTerminada es lo que cada auto informa al coordinador al cerrar una
+// vuelta; mejora indica que es su nueva mejor vuelta personal. Con abandono
+// el auto se retiró en Vuelta sin completarla (Tiempo no se usa) y no informa
+// más vueltas.
+type vueltaTerminada struct {
+	TiempoVueltaAuto
+	mejora   bool
+	abandono bool
+}
+
+// ordenarPorTiempo devuelve los autos (1..n) ordenados por vueltas
+// completadas, de más a menos (así los que abandonaron quedan detrás), y a
+// igual cantidad por tiempo acumulado; ante un empate va primero el de menor
+// número.
+func ordenarPorTiempo(completadas []int, acumulados []float64) []int {
+	orden := make([]int, len(acumulados))
+	for i := range orden {
+		orden[i] = i + 1
+	}
+	sort.SliceStable(orden, func(i, j int) bool {
+		a, b := orden[i]-1, orden[j]-1
+		if completadas[a] != completadas[b] {
+			return completadas[a] > completadas[b]
+		}
+		return acumulados[a] < acumulados[b]
+	})
+	return orden
+}
+
+// coordinarCarrera recibe las vueltas de todos los autos hasta que se cierre
+// vueltas. Avisa cada vez que baja la mejor vuelta entre todos los autos
+// ("Mejor teórica") y, cuando todos completaron una vuelta, reordena por
+// tiempo acumulado, envía la tabla e informa los adelantamientos respecto de
+// la vuelta anterior (en la largada el orden es el número de auto). La tabla
+// completa ("posiciones") se envía tras la vuelta 1; después solo los autos
+// que cambiaron de posición o de mejor vuelta ("leaderboard_delta"). Un auto
+// que abandona conserva su tiempo y su mejor vuelta hasta totalVueltas, para
+// que no frene la clasificación de las vueltas siguientes, y queda detrás de
+// los que siguen en carrera.
+func coordinarCarrera(cantidadAutos, totalVueltas int, topico, formato string, vueltas <-chan vueltaTerminada, enviar chan MensajeWS) {
+	mejorTeorica := TiempoVueltaAuto{}
+	acumulados := make([][]float64, cantidadAutos) // acumulados[auto-1][v-1]
+	mejores := make([][]float64, cantidadAutos)    // mejores[auto-1][v-1]: mejor vuelta del auto tras la vuelta v
+	posicionAnterior := make([]int, cantidadAutos+1)
+	for a := 1; a <= cantidadAutos; a++ {
+		posicionAnterior[a] = a
+	}
+	mejorAnterior := make([]float64, cantidadAutos+1)
+	abandono := make([]int, cantidadAutos+1) // abandono[a]: vuelta en la que abandonó el Auto a (0 = en carrera)
+	siguiente := 1                           // próxima vuelta a clasificar
+
+	for m := range vueltas {
+		if m.abandono {
+			abandono[m.Auto] = m.Vuelta
+			previo, mejor := 0.0, 0.0
+			if n := len(acumulados[m.Auto-1]); n > 0 {
+				previo, mejor = acumulados[m.Auto-1][n-1], mejores[m.Auto-1][n-1]
+			}
+			for len(acumulados[m.Auto-1]) < totalVueltas {
+				acumulados[m.Auto-1] = append(acumulados[m.Auto-1], previo)
+				mejores[m.Auto-1] = append(mejores[m.Auto-1], mejor)
+			}
+		}
+
+		if m.mejora && (mejorTeorica.Auto == 0 || m.Tiempo < mejorTeorica.Tiempo) {
+			mejorTeorica = m.TiempoVueltaAuto
+			enviar <- MensajeWS{
+				Tipo:   "registro",
+				Topico: topico,
+				Texto:  fmt.Sprintf("Mejor teórica: %s (Auto %d, vuelta %d)", formatearTiempo(m.Tiempo, formato), m.Auto, m.Vuelta),
+				Obj:    m.TiempoVueltaAuto,
+			}
+		}
+
+		if !m.abandono {
+			previo := 0.0
+			if n := len(acumulados[m.Auto-1]); n > 0 {
+				previo = acumulados[m.Auto-1][n-1]
+			}
+			acumulados[m.Auto-1] = append(acumulados[m.Auto-1], redondear(previo+m.Tiempo))
+			mejor := 0.0
+			if n := len(mejores[m.Auto-1]); n > 0 {
+				mejor = mejores[m.Auto-1][n-1]
+			}
+			if m.mejora {
+				mejor = m.Tiempo
+			}
+			mejores[m.Auto-1] = append(mejores[m.Auto-1], mejor)
+		}
+
+		for completa(acumulados, siguiente) {
+			tiempos := make([]float64, cantidadAutos)
+			completadas := make([]int, cantidadAutos)
+			for a := range tiempos {
+				tiempos[a] = acumulados[a][siguiente-1]
+				completadas[a] = siguiente
+				if v := abandono[a+1]; v > 0 && v <= siguiente {
+					completadas[a] = v - 1
+				}
+			}
+			orden := ordenarPorTiempo(completadas, tiempos)
+			posicion := make([]int, cantidadAutos+1)
+			for i, a := range orden {
+				posicion[a] = i + 1
+			}
+			if siguiente == 1 {
+				enviar <- mensajePosiciones(topico, siguiente, orden, posicion, tiempos, abandono)
+			} else {
+				enviar <- mensajeDeltaPosiciones(topico, siguiente, orden, posicion, posicionAnterior, mejores, mejorAnterior, tiempos, formato)
+			}
+			for a := 1; a <= cantidadAutos; a++ {
+				mejorAnterior[a] = mejores[a-1][siguiente-1]
+			}
+			for _, a := range orden {
+				for _, b := range orden {
+					// Quedar delante de un auto que abandonó no es un adelantamiento
+					retirado := abandono[b] > 0 && abandono[b] <= siguiente
+					if !retirado && posicionAnterior[a] > posicionAnterior[b] && posicion[a] < posicion[b] {
+						enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Vuelta %d: Auto %d adelanta a Auto %d", siguiente, a, b)}
+					}
+				}
+			}
+			posicionAnterior = posicion
+			siguiente++
+		}
+	}
+}
+
+// mensajePosiciones arma la tabla completa tras la vuelta v: orden son los
+// autos de primero a último, posicion[a] la del Auto a, tiempos[a-1] su
+// tiempo acumulado y abandono[a] la vuelta en la que abandonó (0 = ninguna)
+func mensajePosiciones(topico string, v int, orden, posicion []int, tiempos []float64, abandono []int) MensajeWS {
+	posiciones := PosicionesVuelta{Vuelta: v, Posiciones: make([]PosicionCarrera, len(tiempos))}
+	for a := 1; a <= len(tiempos); a++ {
+		pos := PosicionCarrera{Posicion: posicion[a], Auto: a, TiempoTotal: tiempos[a-1]}
+		if abandono[a] > 0 && abandono[a] <= v {
+			pos.Abandono, pos.VueltaAbandono = true, abandono[a]
+		}
+		posiciones.Posiciones[a-1] = pos
+	}
+	nombres := make([]string, len(orden))
+	for i, a := range orden {
+		nombres[i] = fmt.Sprintf("Auto %d", a)
+		if posiciones.Posiciones[a-1].Abandono {
+			nombres[i] += " (abandono)"
+		}
+	}
+	return MensajeWS{
+		Tipo:   "posiciones",
+		Topico: topico,
+		Texto:  fmt.Sprintf("Posiciones tras la vuelta %d: %s", v, strings.Join(nombres, ", ")),
+		Obj:    posiciones,
+	}
+}
+
+// mensajeDeltaPosiciones arma los cambios de la tabla tras la vuelta v
+// respecto de la anterior, en orden de posición: los autos cuya posición
+// difiere de posicionAnterior o cuya mejor vuelta (mejores[a-1][v-1]) difiere
+// de mejorAnterior
+func mensajeDeltaPosiciones(topico string, v int, orden, posicion, posicionAnterior []int, mejores [][]float64, mejorAnterior, tiempos []float64, formato string) MensajeWS {
+	delta := DeltaPosiciones{Vuelta: v, Cambios: []CambioPosicion{}}
+	var textos []string
+	for _, a := range orden {
+		mejor := mejores[a-1][v-1]
+		if posicion[a] == posicionAnterior[a] && mejor == mejorAnterior[a] {
+			continue
+		}
+		delta.Cambios = append(delta.Cambios, CambioPosicion{Auto: a, Posicion: posicion[a], MejorVuelta: mejor, TiempoTotal: tiempos[a-1]})
+		texto := fmt.Sprintf("Auto %d P%d", a, posicion[a])
+		if mejor != mejorAnterior[a] {
+			texto += fmt.Sprintf(" (mejor vuelta %s)", formatearTiempo(mejor, formato))
+		}
+		textos = append(textos, texto)
+	}
+	texto := fmt.Sprintf("Cambios tras la vuelta %d: sin cambios", v)
+	if len(textos) > 0 {
+		texto = fmt.Sprintf("Cambios tras la vuelta %d: %s", v, strings.Join(textos, ", "))
+	}
+	return MensajeWS{Tipo: "leaderboard_delta", Topico: topico, Texto: texto, Obj: delta}
+}
+
+// completa indica si todos los autos terminaron la vuelta v
+func completa(acumulados [][]float64, v int) bool {
+	for _, a := range acumulados {
+		if len(a) < v {
+			return false
+		}
+	}
+	return true
+}
+
+// ordenLlegada clasifica los resultados por tiempo total de carrera; los
+// autos que abandonaron van al final, primero los que completaron más vueltas
+func ordenLlegada(resultados []ResultadoOpenMP) []PosicionCarrera {
+	totales := make([]float64, len(resultados))
+	completadas := make([]int, len(resultados))
+	for i, r := range resultados {
+		for _, t := range r.Vueltas {
+			totales[i] += t
+		}
+		totales[i] = redondear(totales[i])
+		completadas[i] = r.CantidadVueltas
+	}
+	llegada := make([]PosicionCarrera, 0, len(resultados))
+	for i, a := range ordenarPorTiempo(completadas, totales) {
+		r := resultados[a-1]
+		llegada = append(llegada, PosicionCarrera{Posicion: i + 1, Auto: a, TiempoTotal: totales[a-1], Abandono: r.Abandono, VueltaAbandono: r.VueltaAbandono})
+	}
+	return llegada
+}
+
+// puntosPorPosicion son los puntos de campeonato por defecto de las diez
+// primeras posiciones de llegada
+var puntosPorPosicion = []int{25, 18, 15, 12, 10, 8, 6, 4, 2, 1}
+
+// La vuelta rápida suma puntoVueltaRapida si su auto llegó entre los
+// primeros posicionesVueltaRapida
+const (
+	puntoVueltaRapida      = 1
+	posicionesVueltaRapida = 10
+)
+
+// asignarPuntos fija los Puntos de cada resultado según su posición en
+// llegada y la tabla, más el de la vuelta rápida al auto de mejor, y devuelve
+// los totales de más a menos (empates por posición de llegada) y el auto que
+// sumó la vuelta rápida (0 si nadie). Los que abandonaron no suman.
+func asignarPuntos(resultados []ResultadoOpenMP, llegada []PosicionCarrera, mejor *ResultadoOpenMP, tabla []int) ([]PuntosAuto, int) {
+	totales := make([]PuntosAuto, 0, len(llegada))
+	vueltaRapida := 0
+	for _, pos := range llegada {
+		total := PuntosAuto{Auto: pos.Auto, Posicion: pos.Posicion}
+		if !pos.Abandono {
+			if pos.Posicion <= len(tabla) {
+				total.Puntos = tabla[pos.Posicion-1]
+			}
+			if mejor != nil && mejor.AutoID == pos.Auto && pos.Posicion <= posicionesVueltaRapida {
+				total.Puntos += puntoVueltaRapida
+				total.VueltaRapida = true
+				vueltaRapida = pos.Auto
+			}
+		}
+		resultados[pos.Auto-1].Puntos = total.Puntos
+		totales = append(totales, total)
+	}
+	sort.SliceStable(totales, func(i, j int) bool { return totales[i].Puntos > totales[j].Puntos })
+	return totales, vueltaRapida
+}
 
-// -------------------- Configuración WebSocket --------------------
+// ultimoOpenMP guarda el resumen de la última corrida OpenMP completada
+var ultimoOpenMP struct {
+	sync.Mutex
+	resumen *ResumenOpenMP
+}
 
-// Permite cualquier origen (útil para pruebas locales)
-var actualizador = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// guardarUltimoOpenMP registra r como la última corrida completada
+func guardarUltimoOpenMP(r ResumenOpenMP) {
+	ultimoOpenMP.Lock()
+	defer ultimoOpenMP.Unlock()
+	ultimoOpenMP.resumen = &r
 }
 
-// -------------------- Tipo de mensaje simplificado --------------------
+// borrarUltimoOpenMP olvida la última corrida completada
+func borrarUltimoOpenMP() {
+	ultimoOpenMP.Lock()
+	defer ultimoOpenMP.Unlock()
+	ultimoOpenMP.resumen = nil
+}
 
-// MensajeWS representa cualquier mensaje enviado al cliente vía WebSocket
-type MensajeWS struct {
-	Tipo   string `json:"tipo"`             // "registro", "resumen", "finalizado"
-	Topico string `json:"topico,omitempty"` // "mpi" o "openmp"
-	Texto  string `json:"texto,omitempty"`  // texto del mensaje
+// obtenerUltimoOpenMP devuelve la última corrida completada, o nil si todavía no hubo ninguna
+func obtenerUltimoOpenMP() *ResumenOpenMP {
+	ultimoOpenMP.Lock()
+	defer ultimoOpenMP.Unlock()
+	return ultimoOpenMP.resumen
 }
 
-// -------------------- MPI (anillo de sectores) --------------------
-// correrMPI simula un auto pasando por sectores de manera secuencial
-func correrMPI(sectores int, vueltas int, enviar chan MensajeWS) {
-	if sectores < 1 {
-		enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: "Error: sectores debe ser >= 1"}
-		enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi"}
-		return
+// clasificar devuelve una copia de los resultados ordenada por mejor vuelta
+// ascendente; los empates se desempatan por promedio de vuelta. Los autos
+// sin una vuelta válida (abandonaron antes) van al final.
+func clasificar(resultados []ResultadoOpenMP) []ResultadoOpenMP {
+	clasificacion := append([]ResultadoOpenMP(nil), resultados...)
+	sort.SliceStable(clasificacion, func(i, j int) bool {
+		a, b := clasificacion[i], clasificacion[j]
+		if (a.MejorVuelta == 0) != (b.MejorVuelta == 0) {
+			return b.MejorVuelta == 0
+		}
+		if a.MejorVuelta != b.MejorVuelta {
+			return a.MejorVuelta < b.MejorVuelta
+		}
+		return a.PromedioVuelta < b.PromedioVuelta
+	})
+	return clasificacion
+}
+
+// mejorGeneral devuelve el resultado con la mejor vuelta entre los autos que
+// completaron alguna, o nil si ninguno lo hizo
+func mejorGeneral(resultados []ResultadoOpenMP) *ResultadoOpenMP {
+	var mejor *ResultadoOpenMP
+	for i, r := range resultados {
+		if r.CantidadVueltas == 0 || r.MejorVuelta == 0 {
+			continue // el auto no llegó a informar su resultado o abandonó sin una vuelta válida
+		}
+		if mejor == nil || r.MejorVuelta < mejor.MejorVuelta {
+			mejor = &resultados[i]
+		}
+	}
+	return mejor
+}
+
+// ParametrosOpenMP agrupa la configuración de una simulación OpenMP
+type ParametrosOpenMP struct {
+	Autos     int           `json:"autos"`
+	Vueltas   int           `json:"vueltas"`
+	TiempoMin float64       `json:"tiempo_min"` // tiempo mínimo por vuelta (s)
+	TiempoMax float64       `json:"tiempo_max"` // tiempo máximo por vuelta (s)
+	Retardo   time.Duration `json:"-"`          // pausa entre vueltas (0 = sin pausa)
+
+	// Paradas en boxes: cada PitCada vueltas el auto suma PitTiempo segundos.
+	// Con PitCada = 0 no hay paradas.
+	PitCada   int     `json:"pit_cada"`
+	PitTiempo float64 `json:"pit_tiempo"`
+
+	// Semilla fija la secuencia de tiempos generados; nil usa la hora actual.
+	// Cada auto usa su propia fuente derivada (Semilla + índice del auto) para
+	// que el resultado no dependa del orden en que corren las goroutines.
+	Semilla *int64 `json:"semilla,omitempty"`
+
+	Clima string `json:"clima"` // "seco", "humedo" o "lluvia" (ver multiplicadoresClima)
+
+	// CombustibleInicial son los segundos de penalización por carga de
+	// combustible en la vuelta 1; baja linealmente hasta 0 en la última vuelta.
+	CombustibleInicial float64 `json:"combustible_inicial"`
+
+	// DesgasteMotor son los segundos que el desgaste del motor suma a cada
+	// vuelta por cada vuelta anterior: en la vuelta v se suman
+	// (v-1)*DesgasteMotor. Al superar un umbral sorteado por auto (ver
+	// umbralProblemaMotor) el auto sufre un único problema de motor.
+	DesgasteMotor float64 `json:"desgaste_motor"`
+
+	// SafetyCarProb es la probabilidad (0..1) de que una vuelta se corra detrás
+	// del safety car: todos los autos la marcan en tiempoSafetyCar.
+	SafetyCarProb float64 `json:"safety_car_prob"`
+
+	// ProbAbandono es la probabilidad (0..1) de que un auto abandone en cada
+	// vuelta: deja de correr y termina la carrera sin clasificar.
+	ProbAbandono float64 `json:"prob_abandono"`
+
+	// Puntos son los puntos de campeonato de cada posición de llegada, desde
+	// el ganador; las posiciones sin entrada no suman (ver asignarPuntos)
+	Puntos []int `json:"puntos"`
+
+	// Hilos limita cuántos autos corren a la vez, como num_threads de OpenMP.
+	// Con 0 cada auto tiene su propio hilo.
+	Hilos int `json:"hilos"`
+
+	// AutosConfig ajusta el rendimiento de cada auto; vacío o uno por auto
+	AutosConfig []ConfigAuto `json:"autos_config,omitempty"`
+
+	// FormatoTiempo es el formato de los tiempos en el texto de los registros
+	FormatoTiempo string `json:"formato_tiempo"`
+
+	// Distribucion es cómo se sortea cada tiempo de vuelta dentro del rango
+	// (ver muestrear)
+	Distribucion string `json:"distribucion"`
+
+	// Topico es el tópico de los mensajes de la carrera; vacío = "openmp".
+	// comparar_openmp corre dos carreras a la vez en openmp_a y openmp_b.
+	Topico string `json:"-"`
+}
+
+// topico devuelve el tópico de los mensajes de la carrera
+func (p ParametrosOpenMP) topico() string {
+	if p.Topico == "" {
+		return "openmp"
 	}
-	if vueltas < 1 {
-		vueltas = 1
+	return p.Topico
+}
+
+// ConfigAuto es la configuración individual de un auto OpenMP
+type ConfigAuto struct {
+	// BaseOffset son los segundos que se suman a cada vuelta del auto
+	// (negativo = auto más rápido)
+	BaseOffset float64 `json:"base_offset"`
+}
+
+// offsetAuto devuelve el BaseOffset del auto autoID (desde 0), o 0 sin AutosConfig
+func (p ParametrosOpenMP) offsetAuto(autoID int) float64 {
+	if len(p.AutosConfig) == 0 {
+		return 0
 	}
+	return p.AutosConfig[autoID].BaseOffset
+}
 
-	enviar <- MensajeWS{
-		Tipo:   "registro",
-		Topico: "mpi",
-		Texto:  fmt.Sprintf("Iniciando MPI: %d sectores, %d vueltas", sectores, vueltas),
+// penalizacionCombustible devuelve los segundos que suma la carga de combustible
+// en la vuelta v: inicial en la primera vuelta y 0 en la última.
+func penalizacionCombustible(inicial float64, v, vueltas int) float64 {
+	if vueltas <= 1 {
+		return 0
 	}
+	return inicial * float64(vueltas-v) / float64(vueltas-1)
+}
 
-	for v := 1; v <= vueltas; v++ {
-		enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("=== Vuelta %d ===", v)}
+// tiempoBoxes es la duración por defecto de una parada en boxes (s)
+const tiempoBoxes = 22.0
 
-		for s := 1; s <= sectores; s++ {
-			tiempo := float64(rand.Intn(2300)+1200) / 100.0 // tiempo aleatorio entre 12.00 y 35.99 s
-			enviar <- MensajeWS{
-				Tipo:   "registro",
-				Topico: "mpi",
-				Texto:  fmt.Sprintf("Sector %d recibió tiempo %.2f s (vuelta %d)", s, tiempo, v),
-			}
-			time.Sleep(300 * time.Millisecond) // simulación de paso por sector
+// El umbral de desgaste de motor de cada auto (s por vuelta) se sortea en
+// [umbralMinProblemaMotor, umbralMaxProblemaMotor); al alcanzarlo el auto
+// pierde tiempoProblemaMotor en esa vuelta, una sola vez
+const (
+	umbralMinProblemaMotor = 1.0
+	umbralMaxProblemaMotor = 3.0
+	tiempoProblemaMotor    = 10.0
+)
+
+// umbralProblemaMotor sortea el desgaste con el que el auto autoID (desde 0)
+// tiene su problema de motor. Usa una fuente propia (semilla - 2 - autoID),
+// distinta de la de los autos (semilla + autoID) y el safety car (semilla -
+// 1), para que activar el desgaste no cambie los tiempos de vuelta.
+func umbralProblemaMotor(semilla int64, autoID int) float64 {
+	r := rand.New(rand.NewSource(semilla - 2 - int64(autoID)))
+	return tiempoAleatorio(r, umbralMinProblemaMotor, umbralMaxProblemaMotor)
+}
+
+// tiempoSafetyCar es el tiempo neutralizado de una vuelta detrás del safety car (s)
+const tiempoSafetyCar = 110.0
+
+// vueltasSafetyCar sortea qué vueltas se corren detrás del safety car. Se
+// calcula antes de largar los autos para que la neutralización los alcance a
+// todos en la misma vuelta. Si no quedara ninguna vuelta válida para la mejor
+// vuelta (ni neutralizada ni con parada), se libera la primera sin parada.
+func vueltasSafetyCar(r *rand.Rand, p ParametrosOpenMP, vueltas int) []bool {
+	neutralizadas := make([]bool, vueltas+1) // indexado por número de vuelta
+	hayValida := false
+	for v := 1; v <= vueltas; v++ {
+		neutralizadas[v] = r.Float64() < p.SafetyCarProb
+		if !neutralizadas[v] && !(p.PitCada > 0 && v%p.PitCada == 0) {
+			hayValida = true
 		}
 	}
-
-	enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi", Texto: "MPI finalizado"}
+	for v := 1; !hayValida && v <= vueltas; v++ {
+		if !(p.PitCada > 0 && v%p.PitCada == 0) {
+			neutralizadas[v] = false
+			hayValida = true
+		}
+	}
+	return neutralizadas
 }
 
-// -------------------- OpenMP (vueltas rápidas) --------------------
+// parametrosOpenMPPorDefecto devuelve la configuración usada cuando el cliente no indica valores
+func parametrosOpenMPPorDefecto() ParametrosOpenMP {
+	return ParametrosOpenMP{
+		Autos:     configuracion.Autos,
+		Vueltas:   configuracion.VueltasOpenMP,
+		TiempoMin: tiempoMinVuelta,
+		TiempoMax: tiempoMaxVuelta,
+		Retardo:   milisegundos(configuracion.DelayVueltaMs),
+		PitTiempo: tiempoBoxes,
+		Puntos:    append([]int(nil), puntosPorPosicion...),
 
-// ResultadoOpenMP guarda la mejor vuelta de un auto
-type ResultadoOpenMP struct {
-	AutoID          int
-	MejorVuelta     float64
-	CantidadVueltas int
+		FormatoTiempo: formatoSegundos,
+		Distribucion:  distribucionUniforme,
+	}
+}
+
+// validar comprueba que los parámetros permitan correr la simulación
+func (p ParametrosOpenMP) validar() error {
+	if p.Autos < 1 {
+		return fmt.Errorf("cantidad de autos debe ser >= 1")
+	}
+	if p.Autos > configuracion.MaxAutos {
+		return fmt.Errorf("cantidad de autos debe ser <= %d", configuracion.MaxAutos)
+	}
+	// Como en MPI, 0 vueltas es un error y no se corre una vuelta en su lugar
+	if p.Vueltas < 1 {
+		return fmt.Errorf("vueltas debe ser >= 1")
+	}
+	if p.Vueltas > configuracion.MaxVueltas {
+		return fmt.Errorf("vueltas debe ser <= %d", configuracion.MaxVueltas)
+	}
+	// Con paradas en todas las vueltas no quedaría ninguna vuelta para la mejor vuelta
+	if p.PitCada < 0 || p.PitCada == 1 {
+		return fmt.Errorf("pit_cada debe ser 0 (sin paradas) o >= 2")
+	}
+	if p.PitTiempo < 0 {
+		return fmt.Errorf("pit_tiempo debe ser >= 0")
+	}
+	if p.CombustibleInicial < 0 {
+		return fmt.Errorf("combustible_inicial debe ser >= 0")
+	}
+	if p.SafetyCarProb < 0 || p.SafetyCarProb > 1 {
+		return fmt.Errorf("safety_car_prob debe estar entre 0 y 1")
+	}
+	if p.DesgasteMotor < 0 {
+		return fmt.Errorf("desgaste_motor debe ser >= 0")
+	}
+	if p.ProbAbandono < 0 || p.ProbAbandono > 1 {
+		return fmt.Errorf("prob_abandono debe estar entre 0 y 1")
+	}
+	for i, puntos := range p.Puntos {
+		if puntos < 0 {
+			return fmt.Errorf("los puntos de la posición %d deben ser >= 0", i+1)
+		}
+	}
+	if p.Hilos < 0 {
+		return fmt.Errorf("hilos debe ser >= 0")
+	}
+	if len(p.AutosConfig) > 0 && len(p.AutosConfig) != p.Autos {
+		return fmt.Errorf("autos_config debe tener un elemento por auto (%d), tiene %d", p.Autos, len(p.AutosConfig))
+	}
+	for i, c := range p.AutosConfig {
+		if p.TiempoMin+c.BaseOffset <= 0 {
+			return fmt.Errorf("base_offset del auto %d deja tiempos de vuelta <= 0", i+1)
+		}
+	}
+	if p.Retardo < 0 {
+		return fmt.Errorf("delay_ms debe ser >= 0")
+	}
+	if err := validarFormatoTiempo(p.FormatoTiempo); err != nil {
+		return err
+	}
+	if err := validarDistribucion(p.Distribucion); err != nil {
+		return err
+	}
+	return validarRango(p.TiempoMin, p.TiempoMax)
 }
 
-// correrOpenMP simula varios autos corriendo vueltas rápidas en paralelo usando mutex
-func correrOpenMP(cantidadAutos int, vueltas int, enviar chan MensajeWS) {
-	if cantidadAutos < 1 {
-		enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: "Error: cantidad de autos debe ser >= 1"}
-		enviar <- MensajeWS{Tipo: "finalizado", Topico: "openmp"}
+// correrOpenMP simula varios autos corriendo vueltas rápidas en paralelo.
+// Los resultados de cada auto se recolectan por canal. Con menos de una
+// vuelta informa el error de validación en lugar de correr una.
+// Si se cancela ctx cada auto abandona al terminar la vuelta en curso; con la
+// compuerta en pausa los autos esperan antes de largar la siguiente vuelta.
+func correrOpenMP(ctx context.Context, p ParametrosOpenMP, pausa *compuerta, enviar chan MensajeWS) {
+	cantidadAutos, vueltas, topico := p.Autos, p.Vueltas, p.topico()
+	if err := p.validar(); err != nil {
+		enviar <- mensajeError(topico, err)
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: topico}
 		return
 	}
-	if vueltas < 1 {
-		vueltas = 1
+
+	hilos := p.Hilos
+	if hilos == 0 || hilos > cantidadAutos {
+		hilos = cantidadAutos
 	}
 
-	enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: fmt.Sprintf("Iniciando OpenMP: %d autos, %d vueltas cada uno", cantidadAutos, vueltas)}
+	enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Iniciando OpenMP: %d autos, %d vueltas cada uno, %d hilos", cantidadAutos, vueltas, hilos)}
+	clima, multiplicador := anunciarClima(p.Clima, topico, enviar)
 
-	resultados := make([]ResultadoOpenMP, cantidadAutos)
-	var mutex sync.Mutex
-	var wg sync.WaitGroup
+	// Cada auto envía su resultado por este canal; el colector es el único
+	// que escribe en el slice de resultados.
+	resultadosAutos := make(chan ResultadoOpenMP, cantidadAutos)
+	semilla := resolverSemilla(p.Semilla)
+	// La fuente del safety car no coincide con la de ningún auto (semilla + autoID)
+	safetyCar := vueltasSafetyCar(rand.New(rand.NewSource(semilla-1)), p, vueltas)
+	neutralizadas := 0
+	for v := 1; v <= vueltas; v++ {
+		if safetyCar[v] {
+			neutralizadas++
+			enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Safety car en la vuelta %d", v)}
+		}
+	}
+
+	// Semáforo de hilos: un auto corre solo mientras tiene un número de hilo
+	// tomado del canal, y lo devuelve al terminar para el siguiente auto.
+	hilosLibres := make(chan int, hilos)
+	for h := 1; h <= hilos; h++ {
+		hilosLibres <- h
+	}
+
+	// Cada vuelta terminada pasa por un único coordinador, que lleva la mejor
+	// vuelta entre todos los autos y las posiciones de carrera.
+	vueltasTerminadas := make(chan vueltaTerminada, cantidadAutos)
+	coordinadorListo := make(chan struct{})
+	go func() {
+		defer close(coordinadorListo)
+		coordinarCarrera(cantidadAutos, vueltas, topico, p.FormatoTiempo, vueltasTerminadas, enviar)
+	}()
+
+	// Largada: los autos que toman hilo antes de la luz verde avisan por
+	// enGrilla y esperan a que se cierre largada para correr la vuelta 1 a la
+	// vez. Los de tandas siguientes toman el hilo cuando ya está cerrada y
+	// largan directamente.
+	enGrilla := make(chan struct{}, cantidadAutos)
+	largada := make(chan struct{})
 
+	var wg sync.WaitGroup
 	for auto := 0; auto < cantidadAutos; auto++ {
 		wg.Add(1)
 		go func(autoID int) {
 			defer wg.Done()
+			var hilo int
+			select {
+			case hilo = <-hilosLibres:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { hilosLibres <- hilo }()
+			enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Auto %d corre en el hilo %d", autoID+1, hilo)}
+			select {
+			case <-largada:
+			default:
+				enGrilla <- struct{}{}
+				select {
+				case <-largada:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			aleatorio := rand.New(rand.NewSource(semilla + int64(autoID)))
 			mejor := 1e9
+			historial := make([]float64, 0, vueltas)
+			suma := 0.0
+			paradas := 0
+			abandono := 0
+			umbralMotor, problemaMotor := 0.0, 0
+			if p.DesgasteMotor > 0 {
+				umbralMotor = umbralProblemaMotor(semilla, autoID)
+			}
 			for v := 1; v <= vueltas; v++ {
-				tiempoVuelta := float64(rand.Intn(2099)+7500) / 100.0
-				time.Sleep(200 * time.Millisecond)
-				enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: fmt.Sprintf("Auto %d - Vuelta %d: %.2f s", autoID+1, v, tiempoVuelta)}
-				if tiempoVuelta < mejor {
+				// Sin prob_abandono no se sortea, así la secuencia de tiempos de
+				// una semilla es la misma de siempre
+				if p.ProbAbandono > 0 && aleatorio.Float64() < p.ProbAbandono {
+					if !esperar(ctx, p.Retardo) || !pausa.pasar(ctx) {
+						return
+					}
+					abandono = v
+					enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Auto %d abandona en vuelta %d", autoID+1, v)}
+					vueltasTerminadas <- vueltaTerminada{TiempoVueltaAuto: TiempoVueltaAuto{Auto: autoID + 1, Vuelta: v}, abandono: true}
+					break
+				}
+				combustible := penalizacionCombustible(p.CombustibleInicial, v, vueltas)
+				tiempoVuelta := redondear(muestrear(p.Distribucion, p.TiempoMin, p.TiempoMax, aleatorio)*multiplicador + combustible + p.offsetAuto(autoID))
+				// El desgaste se acumula en todas las vueltas, pero en las
+				// neutralizadas no se nota ni provoca el problema de motor
+				desgaste := float64(v-1) * p.DesgasteMotor
+				averia := false
+				if safetyCar[v] {
+					// El tiempo sorteado se descarta para no alterar la secuencia del auto
+					tiempoVuelta, combustible, desgaste = tiempoSafetyCar, 0, 0
+				} else if desgaste > 0 {
+					tiempoVuelta = redondear(tiempoVuelta + desgaste)
+					if averia = problemaMotor == 0 && desgaste >= umbralMotor; averia {
+						problemaMotor = v
+						tiempoVuelta = redondear(tiempoVuelta + tiempoProblemaMotor)
+					}
+				}
+				enBoxes := p.PitCada > 0 && v%p.PitCada == 0
+				if enBoxes {
+					tiempoVuelta = redondear(tiempoVuelta + p.PitTiempo)
+					paradas++
+				}
+				if !esperar(ctx, p.Retardo) || !pausa.pasar(ctx) {
+					return
+				}
+				historial = append(historial, tiempoVuelta)
+				suma += tiempoVuelta
+				if enBoxes {
+					enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Auto %d entra a boxes (+%s)", autoID+1, formatearTiempo(p.PitTiempo, p.FormatoTiempo))}
+				}
+				texto := fmt.Sprintf("Auto %d - Vuelta %d: %s", autoID+1, v, formatearTiempo(tiempoVuelta, p.FormatoTiempo))
+				if combustible > 0 {
+					texto += fmt.Sprintf(" (+%s por combustible)", formatearTiempo(combustible, p.FormatoTiempo))
+				}
+				if desgaste > 0 {
+					texto += fmt.Sprintf(" (+%s por desgaste de motor)", formatearTiempo(desgaste, p.FormatoTiempo))
+				}
+				if averia {
+					enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Auto %d - problema de motor en la vuelta %d, +%s", autoID+1, v, formatearTiempo(tiempoProblemaMotor, p.FormatoTiempo))}
+				}
+				if safetyCar[v] {
+					texto += " (safety car)"
+				}
+				enviar <- MensajeWS{
+					Tipo:   "registro",
+					Topico: topico,
+					Texto:  texto,
+					Obj:    TiempoVueltaAuto{Auto: autoID + 1, Vuelta: v, Tiempo: tiempoVuelta},
+				}
+				// Las vueltas con parada o neutralizadas no compiten por la mejor vuelta
+				mejora := !enBoxes && !safetyCar[v] && tiempoVuelta < mejor
+				if mejora {
 					mejor = tiempoVuelta
-					enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: fmt.Sprintf("Auto %d - Nueva mejor vuelta: %.2f s", autoID+1, mejor)}
+					enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: fmt.Sprintf("Auto %d - Nueva mejor vuelta: %s", autoID+1, formatearTiempo(mejor, p.FormatoTiempo))}
 				}
+				vueltasTerminadas <- vueltaTerminada{TiempoVueltaAuto: TiempoVueltaAuto{Auto: autoID + 1, Vuelta: v, Tiempo: tiempoVuelta}, mejora: mejora}
+				informarProgreso(ctx, v, 0)
+			}
+			if mejor == 1e9 {
+				mejor = 0 // abandonó sin completar una vuelta válida
+			}
+			promedio := 0.0
+			if len(historial) > 0 {
+				promedio = redondear(suma / float64(len(historial)))
+			}
+			resultadosAutos <- ResultadoOpenMP{
+				AutoID:          autoID + 1,
+				MejorVuelta:     mejor,
+				CantidadVueltas: len(historial),
+				Vueltas:         historial,
+				PromedioVuelta:  promedio,
+				ParadasBoxes:    paradas,
+				BaseOffset:      p.offsetAuto(autoID),
+				Stints:          armarStints(p, safetyCar, historial),
+				Abandono:        abandono > 0,
+				VueltaAbandono:  abandono,
+				DesgasteMotor:   redondear(float64(len(historial)) * p.DesgasteMotor),
+				ProblemaMotor:   problemaMotor,
 			}
-			// Mutex para proteger escritura en slice compartido
-			mutex.Lock()
-			resultados[autoID] = ResultadoOpenMP{AutoID: autoID + 1, MejorVuelta: mejor, CantidadVueltas: vueltas}
-			mutex.Unlock()
 		}(auto)
 	}
 
-	wg.Wait()
+	// Hay un auto en la grilla por hilo; con todos listos se da la largada
+grilla:
+	for listos := 0; listos < hilos; listos++ {
+		select {
+		case <-enGrilla:
+		case <-ctx.Done():
+			break grilla
+		}
+	}
+	if ctx.Err() == nil {
+		enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: "¡Luz verde!"}
+	}
+	close(largada)
+
+	// Cierra los canales cuando todos los autos terminaron para cortar el
+	// colector y el coordinador
+	go func() {
+		wg.Wait()
+		close(resultadosAutos)
+		close(vueltasTerminadas)
+	}()
+
+	resultados := make([]ResultadoOpenMP, cantidadAutos)
+	for r := range resultadosAutos {
+		resultados[r.AutoID-1] = r
+	}
+	<-coordinadorListo
 
-	// Calcula mejor vuelta general
-	mejorGeneral := ResultadoOpenMP{AutoID: -1, MejorVuelta: 1e9}
-	mutex.Lock()
-	for _, r := range resultados {
-		if r.MejorVuelta < mejorGeneral.MejorVuelta {
-			mejorGeneral = r
+	if ctx.Err() != nil {
+		enviar <- MensajeWS{Tipo: "finalizado", Topico: topico, Texto: "OpenMP detenido"}
+		return
+	}
+
+	mejor := mejorGeneral(resultados)
+	llegada := ordenLlegada(resultados)
+	puntos, vueltaRapida := asignarPuntos(resultados, llegada, mejor, p.Puntos)
+	textoMejor := "sin resultados"
+	if mejor != nil {
+		textoMejor = fmt.Sprintf("%+v", *mejor)
+	}
+
+	clasificacion := clasificar(resultados)
+	var tabla strings.Builder
+	for i, r := range clasificacion {
+		if r.MejorVuelta == 0 {
+			fmt.Fprintf(&tabla, "\n%d. Auto %d - sin vuelta válida", i+1, r.AutoID)
+			continue
+		}
+		fmt.Fprintf(&tabla, "\n%d. Auto %d - %.2f s", i+1, r.AutoID, r.MejorVuelta)
+	}
+	var tablaLlegada strings.Builder
+	for _, pos := range llegada {
+		if pos.Abandono {
+			fmt.Fprintf(&tablaLlegada, "\n%d. Auto %d - abandono en vuelta %d", pos.Posicion, pos.Auto, pos.VueltaAbandono)
+			continue
+		}
+		fmt.Fprintf(&tablaLlegada, "\n%d. Auto %d - %.2f s", pos.Posicion, pos.Auto, pos.TiempoTotal)
+	}
+	var tablaPuntos strings.Builder
+	for _, total := range puntos {
+		fmt.Fprintf(&tablaPuntos, "\nAuto %d - %d pts", total.Auto, total.Puntos)
+		if total.VueltaRapida {
+			fmt.Fprintf(&tablaPuntos, " (+%d vuelta rápida)", puntoVueltaRapida)
 		}
 	}
-	mutex.Unlock()
 
-	enviar <- MensajeWS{Tipo: "resumen", Topico: "openmp", Texto: fmt.Sprintf("Resultados OpenMP:\nMejor por auto: %+v\nMejor general: %+v", resultados, mejorGeneral)}
+	resumen := ResumenOpenMP{
+		MejorPorAuto:       resultados,
+		MejorGeneral:       mejor,
+		Clasificacion:      clasificacion,
+		Clima:              clima,
+		CombustibleInicial: redondear(penalizacionCombustible(p.CombustibleInicial, 1, vueltas)),
+		CombustibleFinal:   redondear(penalizacionCombustible(p.CombustibleInicial, vueltas, vueltas)),
+		VueltasSafetyCar:   neutralizadas,
+		OrdenLlegada:       llegada,
+		TablaPuntos:        p.Puntos,
+		Puntos:             puntos,
+		VueltaRapida:       vueltaRapida,
+	}
+	var todas []float64
+	for _, r := range resultados {
+		todas = append(todas, r.Vueltas...)
+	}
+	resumen.Estadisticas = calcularEstadisticas(todas)
+	// Las carreras de comparar_openmp (con tópico propio) no son "la última
+	// corrida OpenMP": cada una pisaría a la otra
+	if p.Topico == "" {
+		guardarUltimoOpenMP(resumen)
+	}
+
+	enviar <- MensajeWS{
+		Tipo:   "resumen",
+		Topico: topico,
+		Texto:  fmt.Sprintf("Resultados OpenMP:\nMejor por auto: %+v\nMejor general: %s\nClasificación:%s\nOrden de llegada:%s\nPuntos:%s", resultados, textoMejor, tabla.String(), tablaLlegada.String(), tablaPuntos.String()),
+		Obj:    resumen,
+	}
 	//enviar <- MensajeWS{Tipo: "resumen", Topico: "mpi", Texto: "OpenMP finalizado"}
-	enviar <- MensajeWS{Tipo: "finalizado", Topico: "openmp", Texto: "OpenMP finalizado"}
+	enviar <- MensajeWS{Tipo: "finalizado", Topico: topico, Texto: "OpenMP finalizado"}
 }
 
 // -------------------- WebSocket handler --------------------
 
+// conexionesWS cuenta los WebSocket abiertos. http.Server.Shutdown no espera
+// a las conexiones secuestradas por el upgrade, así que main las espera aparte.
+var conexionesWS sync.WaitGroup
+
 func wsHandler(w http.ResponseWriter, r *http.Request) {
+	// Formato de los mensajes al cliente: JSON (por defecto) o MessagePack
+	escribir := func(conn *websocket.Conn, msg MensajeWS) error { return conn.WriteJSON(msg) }
+	switch formato := r.URL.Query().Get("fmt"); formato {
+	case "", "json":
+	case "msgpack":
+		escribir = func(conn *websocket.Conn, msg MensajeWS) error {
+			datos, err := codificarMsgpack(msg)
+			if err != nil {
+				return err
+			}
+			return conn.WriteMessage(websocket.BinaryMessage, datos)
+		}
+	default:
+		http.Error(w, fmt.Sprintf("formato no soportado: %q (usar json o msgpack)", formato), http.StatusBadRequest)
+		return
+	}
+
+	version, err := negociarProtocolo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Todos los logs de la conexión y de sus corridas llevan su id, así se
+	// puede seguir la actividad de un cliente con grep
+	idConexion := nuevoRunID()
+	bitacora := slog.With("conexion", idConexion, "remoto", r.RemoteAddr, "version", version)
 	conn, err := actualizador.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("Error al actualizar a websocket:", err)
+		bitacora.Warn("error al actualizar a websocket", "error", err)
 		return
 	}
+	bitacora.Info("conexión WebSocket abierta")
+	conexionesWS.Add(1)
+	defer conexionesWS.Done()
 	defer conn.Close()
 
+	// El contexto de la petición se cancela cuando el servidor se apaga
+	ctxConexion := r.Context()
+
 	enviar := make(chan MensajeWS, 100)
-	defer close(enviar)
+	escritorTerminado := make(chan struct{})
+	codigo := websocket.CloseNormalClosure // del aviso de cierre; el bucle principal puede cambiarlo
+	defer func() {
+		// Cierra enviar, deja que el escritor vacíe lo pendiente y recién
+		// entonces avisa el cierre al cliente.
+		close(enviar)
+		select {
+		case <-escritorTerminado:
+		case <-time.After(5 * time.Second):
+		}
+		if ctxConexion.Err() != nil {
+			codigo = websocket.CloseGoingAway
+		}
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(codigo, ""), time.Now().Add(time.Second))
+	}()
 
-	// Goroutine que envía mensajes de forma segura
+	// Las simulaciones escriben en enviar y colaSalida les entrega los
+	// mensajes al escritor sin dejar que un cliente lento las frene.
+	salida := make(chan MensajeWS)
+	go colaSalida(enviar, salida, bitacora)
+
+	// Goroutine que envía mensajes de forma segura. Si la escritura falla
+	// sigue vaciando salida hasta que se cierre, para que ninguna simulación
+	// quede bloqueada enviando a un cliente que ya no existe.
 	go func() {
-		for msg := range enviar {
-			if err := conn.WriteJSON(msg); err != nil {
-				log.Println("Error escribiendo en websocket:", err)
-				return
+		defer close(escritorTerminado)
+		for msg := range salida {
+			for _, parte := range fragmentarResumen(msg, maxTamanioResumen) {
+				if err := escribir(conn, parte); err != nil {
+					bitacora.Warn("error escribiendo en websocket", "error", err)
+					for range salida {
+					}
+					return
+				}
 			}
 		}
 	}()
 
-	// Bucle principal de lectura de comandos
-	for {
-		var comando map[string]any
-		if err := conn.ReadJSON(&comando); err != nil {
-			log.Println("Conexión cerrada o error de lectura:", err)
-			return
-		}
-		switch comando["action"] {
-		case "iniciar_mpi":
-			sectores := 5
-			vueltas := 3
-			if v, ok := comando["sectores"].(float64); ok {
-				sectores = int(v)
+	// Modo espectador: la conexión recibe lo que otros clientes difundan.
+	// Se quita del hub antes de cerrar enviar (los defer corren en orden inverso).
+	espectadores.registrar(enviar)
+	defer espectadores.quitar(enviar)
+
+	// Goroutine que lee comandos del cliente y los entrega al bucle principal.
+	// Cada pong o comando extiende el plazo de lectura; si vence, la lectura
+	// falla como con cualquier conexión cortada. Lo mismo un comando de más de
+	// maxTamanioComando (ver leerComando).
+	comandos := make(chan []byte)
+	errLectura := make(chan error, 1)
+	lectorTerminado := make(chan struct{})
+	defer close(lectorTerminado)
+	conn.SetReadLimit(limiteLecturaWS)
+	conn.SetReadDeadline(time.Now().Add(esperaPong))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(esperaPong))
+	})
+	go func() {
+		for {
+			comando, err := leerComando(conn)
+			if err == nil {
+				err = conn.SetReadDeadline(time.Now().Add(esperaPong))
+			}
+			if err != nil {
+				errLectura <- err
+				return
 			}
-			if v, ok := comando["vueltas"].(float64); ok {
-				vueltas = int(v)
+			select {
+			case comandos <- comando:
+			case <-lectorTerminado:
+				return
 			}
-			go correrMPI(sectores, vueltas, enviar)
-		case "iniciar_openmp":
-			autos := 4
-			vueltas := 5
-			if v, ok := comando["autos"].(float64); ok {
-				autos = int(v)
+		}
+	}()
+
+	// Goroutine de keepalive. WriteControl puede usarse en paralelo con el
+	// escritor, así los pings no pasan por enviar.
+	go func() {
+		ticker := time.NewTicker(intervaloPing)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+					bitacora.Debug("no se pudo enviar el ping", "error", err)
+					return
+				}
+			case <-lectorTerminado:
+				return
 			}
-			if v, ok := comando["vueltas"].(float64); ok {
-				vueltas = int(v)
+		}
+	}()
+
+	// Antes de cerrar enviar (defer anterior) se detienen todas las
+	// simulaciones y se espera a que retornen: así nadie envía a un canal cerrado.
+	ses := nuevaSesion(ctxConexion, enviar, idConexion, version, bitacora)
+	defer ses.detenerTodas()
+
+	// Bucle principal: atiende comandos hasta que se cierre la conexión o se apague el servidor
+	for {
+		select {
+		case <-ctxConexion.Done():
+			// Las simulaciones ya recibieron la cancelación; se espera su
+			// finalizado para que el cliente lo reciba antes del aviso.
+			ses.detenerTodas()
+			enviar <- MensajeWS{Tipo: "registro", Texto: "El servidor se está apagando"}
+			return
+		case err := <-errLectura:
+			var errRed net.Error
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				bitacora.Info("conexión WebSocket cerrada por el cliente")
+			} else if errors.Is(err, errComandoGrande) {
+				// Todavía se puede escribir: el error llega antes del cierre
+				bitacora.Warn("comando demasiado grande, se cierra la conexión", "maximo", maxTamanioComando)
+				enviar <- mensajeError("", fmt.Errorf("%w, se cierra la conexión", err))
+				codigo = websocket.CloseMessageTooBig
+			} else if errors.Is(err, websocket.ErrReadLimit) {
+				// gorilla ya avisó el cierre (1009) sin leer el mensaje
+				bitacora.Warn("mensaje demasiado grande, se cierra la conexión sin leerlo", "limite", limiteLecturaWS)
+			} else if errors.As(err, &errRed) && errRed.Timeout() {
+				bitacora.Warn("conexión WebSocket sin respuesta al ping, se cierra", "espera", esperaPong)
+			} else {
+				bitacora.Warn("conexión WebSocket cerrada por error de lectura", "error", err)
 			}
-			go correrOpenMP(autos, vueltas, enviar)
-		default:
-			enviar <- MensajeWS{Tipo: "registro", Texto: fmt.Sprintf("Comando no reconocido: %v", comando["action"])}
+			return
+		case comando := <-comandos:
+			ses.procesarComando(comando)
 		}
 	}
 }
 
 // -------------------- HTTP handler --------------------
 
-var plantillaIndex = template.Must(template.New("index").Parse(htmlIndex))
+// plantillas contiene la interfaz HTML embebida en el binario
+//
+//go:embed templates/index.html
+var plantillas embed.FS
+
+var plantillaIndex = template.Must(template.ParseFS(plantillas, "templates/index.html"))
+
+// recursoEstatico es un archivo que no cambia mientras corre el servidor,
+// servido con ETag (hash del contenido) y Last-Modified para que el navegador
+// lo revalide y reciba 304 si no cambió
+type recursoEstatico struct {
+	nombre     string // da el Content-Type por su extensión
+	contenido  []byte
+	etag       string
+	modificado time.Time
+}
+
+// nuevoRecursoEstatico calcula el ETag de contenido. Los archivos embebidos
+// no tienen fecha, así que se toma como modificación el arranque del servidor.
+func nuevoRecursoEstatico(nombre string, contenido []byte) *recursoEstatico {
+	suma := sha256.Sum256(contenido)
+	return &recursoEstatico{
+		nombre:     nombre,
+		contenido:  contenido,
+		etag:       `"` + hex.EncodeToString(suma[:8]) + `"`,
+		modificado: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// ServeHTTP responde el recurso; http.ServeContent atiende If-None-Match e
+// If-Modified-Since (304), HEAD y rangos
+func (rec *recursoEstatico) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", rec.etag)
+	w.Header().Set("Cache-Control", "no-cache") // revalidar siempre, responde 304 si no cambió
+	http.ServeContent(w, r, rec.nombre, rec.modificado, bytes.NewReader(rec.contenido))
+}
+
+// paginaIndex es la interfaz ya renderizada: la plantilla no recibe datos,
+// así que el resultado es el mismo en cada petición
+var paginaIndex = func() *recursoEstatico {
+	var html bytes.Buffer
+	if err := plantillaIndex.Execute(&html, nil); err != nil {
+		panic(err)
+	}
+	return nuevoRecursoEstatico("index.html", html.Bytes())
+}()
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
-	plantillaIndex.Execute(w, nil)
+	paginaIndex.ServeHTTP(w, r)
 }
 
 // -------------------- Main --------------------
 
+// tiempoApagado es el máximo que se espera a que terminen las conexiones al apagar
+const tiempoApagado = 10 * time.Second
+
+// direccionPorDefecto resuelve la dirección de escucha: variable ADDR o ":8080"
+func direccionPorDefecto() string {
+	if addr := os.Getenv("ADDR"); addr != "" {
+		return addr
+	}
+	return ":8080"
+}
+
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	addr := flag.String("addr", direccionPorDefecto(), "dirección de escucha del servidor (también variable ADDR)")
+	nivelLog := flag.String("loglevel", "info", "nivel mínimo de los logs: debug, info, warn o error")
+	flag.DurationVar(&duracionMaxima, "maxduracion", duracionMaxima, "tiempo máximo que puede correr cada simulación")
+	flag.StringVar(&secretoAdmin, "admin-secret", secretoAdmin, "secreto de la cabecera X-Admin-Secret que habilita /api/admin/ (también variable ADMIN_SECRET; vacío = deshabilitado)")
+	rutaHistorial := flag.String("historial", "historial.json", "archivo donde se guardan las corridas terminadas (vacío = solo en memoria)")
+	rutaConfig := flag.String("config", "config.json", "archivo JSON con los valores por defecto y límites de las simulaciones")
+	bench := flag.Int("bench", 0, "corre N simulaciones OpenMP sin pausas ni servidor, informa el rendimiento y termina")
+	benchParams := flag.String("bench-params", "", `parámetros de las simulaciones de -bench, como el comando iniciar_openmp (p. ej. '{"autos":8,"vueltas":50}')`)
+	perfilCPU := flag.String("cpuprofile", "", "con -bench, archivo donde escribir el perfil de CPU (pprof)")
+	perfilMemoria := flag.String("memprofile", "", "con -bench, archivo donde escribir el perfil de asignaciones (pprof)")
+	flag.Parse()
+
+	var nivel slog.Level
+	if err := nivel.UnmarshalText([]byte(*nivelLog)); err != nil {
+		fmt.Fprintf(os.Stderr, "-loglevel inválido %q: usar debug, info, warn o error\n", *nivelLog)
+		os.Exit(2)
+	}
+	if duracionMaxima <= 0 {
+		fmt.Fprintln(os.Stderr, "-maxduracion debe ser mayor que 0")
+		os.Exit(2)
+	}
+	c, err := cargarConfig(*rutaConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configuración inválida: %v\n", err)
+		os.Exit(2)
+	}
+	configuracion = c
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: nivel})))
+	inicioServidor = time.Now()
+
+	if *bench < 0 {
+		fmt.Fprintln(os.Stderr, "-bench debe ser >= 0")
+		os.Exit(2)
+	}
+	if *bench > 0 {
+		if err := correrBenchmark(*bench, *benchParams, *perfilCPU, *perfilMemoria, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "benchmark: %v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	if *rutaHistorial != "" {
+		h, err := abrirHistorialArchivo(*rutaHistorial)
+		if err != nil {
+			slog.Error("no se pudo abrir el historial", "error", err)
+			os.Exit(1)
+		}
+		historialCorridas = h
+	}
 
 	http.HandleFunc("/", indexHandler)
 	http.HandleFunc("/ws", wsHandler)
+	http.HandleFunc("/api/mpi", apiMPIHandler)
+	http.HandleFunc("/api/openmp", apiOpenMPHandler)
+	http.HandleFunc("/api/openmp/ultimo.csv", apiUltimoOpenMPCSVHandler)
+	http.HandleFunc("/api/comandos", apiComandosHandler)
+	http.HandleFunc("/api/estimar", apiEstimarHandler)
+	http.HandleFunc("/api/stream/", apiStreamHandler)
+	http.HandleFunc("/api/historial", apiHistorialHandler)
+	http.HandleFunc("/api/historial/", apiHistorialHandler)
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/metrics", metricsHandler)
+	http.HandleFunc("/api/admin/stop-all", apiDetenerTodasHandler)
+
+	// ctx se cancela con SIGINT/SIGTERM (Ctrl+C, docker stop, systemd)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	servidor := &http.Server{
+		Addr: *addr,
+		// Todas las peticiones heredan ctx: al apagar se cancelan las simulaciones en curso
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		url := "http://" + *addr
+		if strings.HasPrefix(*addr, ":") {
+			url = "http://localhost" + *addr
+		}
+		slog.Info("servidor corriendo", "url", url)
+		if err := servidor.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("no se pudo iniciar el servidor", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("apagando servidor")
+
+	ctxApagado, cancelar := context.WithTimeout(context.Background(), tiempoApagado)
+	defer cancelar()
+	if err := servidor.Shutdown(ctxApagado); err != nil {
+		slog.Error("error al apagar el servidor", "error", err)
+	}
 
-	addr := ":8080"
-	fmt.Println("Servidor corriendo en http://localhost" + addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
-}
-
-// -------------------- HTML + JS embebido --------------------
-
-const htmlIndex = `<!doctype html>
-<html>
-<head>
-<meta charset="utf-8"/>
-<title>Simulaciones MPI / OpenMP - Fórmula1</title>
-<style>
-body { font-family: Arial, sans-serif; margin: 16px; }
-.col { display:inline-block; vertical-align:top; margin-right:20px; width:45%; }
-textarea{ width:100%; height:300px; }
-input[type="number"]{ width:80px; }
-button{ padding:8px 12px; margin-top:6px; }
-.log-mpi{ background:#f0f8ff; padding:8px; border-radius:6px; height:320px; overflow:auto;}
-.log-openmp{ background:#fff8f0; padding:8px; border-radius:6px; height:320px; overflow:auto;}
-</style>
-</head>
-<body>
-<h2>Simulaciones Fórmula1 — MPI (sectores) y OpenMP (vueltas rápidas)</h2>
-<div style="display:flex; gap: 16px;">
-  <div class="col">
-    <h3>MPI - Sectores (anillo)</h3>
-    <label>Cantidad de sectores: <input id="mpi-sectores" type="number" value="5" min="1"></label><br>
-    <label>Vueltas: <input id="mpi-vueltas" type="number" value="3" min="1"></label><br>
-    <button id="start-mpi">Iniciar MPI</button>
-    <div style="margin-top:10px;">
-      <h4>Salida MPI</h4>
-      <div id="mpi-log" class="log-mpi"></div>
-    </div>
-  </div>
-
-  <div class="col">
-    <h3>OpenMP - Vueltas rápidas</h3>
-    <label>Autos: <input id="openmp-autos" type="number" value="4" min="1"></label><br>
-    <label>Vueltas por auto: <input id="openmp-vueltas" type="number" value="5" min="1"></label><br>
-    <button id="start-openmp">Iniciar OpenMP</button>
-    <div style="margin-top:10px;">
-      <h4>Salida OpenMP</h4>
-      <div id="openmp-log" class="log-openmp"></div>
-    </div>
-  </div>
-</div>
-
-<script>
-const ws = new WebSocket("ws://" + location.host + "/ws");
-const mpiLog = document.getElementById("mpi-log");
-const openmpLog = document.getElementById("openmp-log");
-
-ws.onopen = () => appendAmbos("Conexión WebSocket establecida.");
-ws.onclose = () => appendAmbos("WebSocket cerrado.");
-ws.onerror = (e) => appendAmbos("Error WebSocket: " + e);
-
-ws.onmessage = (evt) => {
-  try {
-    const msg = JSON.parse(evt.data);
-    if(msg.topico==="mpi") append(mpiLog, msg.texto);
-    else if(msg.topico==="openmp") append(openmpLog, msg.texto);
-    else appendAmbos(msg.texto);
-  } catch(e){
-    appendAmbos("Mensaje no JSON: "+evt.data);
-  }
-};
-
-function append(target,text){ const p=document.createElement("div"); p.innerHTML=text; target.appendChild(p); target.scrollTop=target.scrollHeight;}
-function appendAmbos(text){ append(mpiLog,text); append(openmpLog,text);}
-
-document.getElementById("start-mpi").onclick = ()=>{
-  const sectores=parseInt(document.getElementById("mpi-sectores").value)||5;
-  const vueltas=parseInt(document.getElementById("mpi-vueltas").value)||3;
-  ws.send(JSON.stringify({action:"iniciar_mpi",sectores:sectores,vueltas:vueltas}));
-  append(mpiLog,"<b>Comando enviado: iniciar MPI</b>");
-};
-
-document.getElementById("start-openmp").onclick = ()=>{
-  const autos=parseInt(document.getElementById("openmp-autos").value)||4;
-  const vueltas=parseInt(document.getElementById("openmp-vueltas").value)||5;
-  ws.send(JSON.stringify({action:"iniciar_openmp",autos:autos,vueltas:vueltas}));
-  append(openmpLog,"<b>Comando enviado: iniciar OpenMP</b>");
-};
-</script>
-</body>
-</html>
-`
+	// Espera a que cada WebSocket avise a su cliente y se cierre
+	cerradas := make(chan struct{})
+	go func() {
+		conexionesWS.Wait()
+		close(cerradas)
+	}()
+	select {
+	case <-cerradas:
+	case <-ctxApagado.Done():
+		slog.Warn("tiempo de apagado agotado con conexiones WebSocket abiertas")
+	}
+	slog.Info("servidor detenido")
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMain(m *testing.M) {
+	// Los logs del servidor no aportan a la salida de los tests
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// semillaPrueba fija los tiempos sorteados en los tests
+var semillaPrueba = int64(42)
+
+// correrHastaElFinal corre la simulación sin pausas y devuelve todos sus mensajes
+func correrHastaElFinal(correr func(ctx context.Context, enviar chan MensajeWS)) []MensajeWS {
+	var mensajes []MensajeWS
+	recorrerMensajes(context.Background(), correr, func(msg MensajeWS) {
+		mensajes = append(mensajes, msg)
+	})
+	return mensajes
+}
+
+// parametrosMPIPrueba son parámetros MPI deterministas y sin pausas
+func parametrosMPIPrueba(sectores, vueltas int) ParametrosMPI {
+	p := parametrosMPIPorDefecto()
+	p.Sectores, p.Vueltas, p.Retardo = sectores, vueltas, 0
+	semilla := semillaPrueba
+	p.Semilla = &semilla
+	return p
+}
+
+func mensajesMPI(p ParametrosMPI) []MensajeWS {
+	return correrHastaElFinal(func(ctx context.Context, enviar chan MensajeWS) {
+		correrMPI(ctx, p, nil, enviar)
+	})
+}
+
+// sectoresEmitidos devuelve los registros de sector en el orden emitido
+func sectoresEmitidos(mensajes []MensajeWS) []TiempoSector {
+	var sectores []TiempoSector
+	for _, msg := range mensajes {
+		if sector, ok := msg.Obj.(TiempoSector); ok && msg.Tipo == "registro" && sector.Color != "" {
+			sectores = append(sectores, sector)
+		}
+	}
+	return sectores
+}
+
+func TestColorSectorUsaSoloLoYaCorrido(t *testing.T) {
+	for _, pipeline := range []bool{false, true} {
+		p := parametrosMPIPrueba(4, 8)
+		p.Pipeline = pipeline
+		sectores := sectoresEmitidos(mensajesMPI(p))
+		if len(sectores) != 4*8 {
+			t.Fatalf("pipeline=%v: %d sectores, se esperaban %d", pipeline, len(sectores), 4*8)
+		}
+		// En pipeline cada etapa recorre las vueltas en orden, así que el
+		// mejor hasta el momento de cada sector se puede recalcular en orden
+		mejores := map[int]float64{}
+		for _, s := range sectores {
+			mejor, hay := mejores[s.Sector]
+			nuevoMejor := !hay || s.Tiempo < mejor
+			if nuevoMejor {
+				mejores[s.Sector] = s.Tiempo
+			}
+			if (s.Color == colorMorado) != nuevoMejor {
+				t.Errorf("pipeline=%v: sector %d vuelta %d (%.2f, mejor previo %.2f): color %s", pipeline, s.Sector, s.Vuelta, s.Tiempo, mejor, s.Color)
+			}
+			if s.Vuelta == 1 && s.Color != colorMorado {
+				t.Errorf("pipeline=%v: el primer paso por el sector %d debería ser morado, es %s", pipeline, s.Sector, s.Color)
+			}
+		}
+	}
+}
+
+func TestCorrerMPIEmiteUnaCorridaCompleta(t *testing.T) {
+	const sectores, vueltas = 3, 4
+	mensajes := mensajesMPI(parametrosMPIPrueba(sectores, vueltas))
+	var inicios, resumenes int
+	for _, msg := range mensajes {
+		if msg.Tipo == "registro" && strings.HasPrefix(msg.Texto, "Iniciando") {
+			inicios++
+		}
+		if msg.Tipo == "resumen" {
+			resumenes++
+		}
+	}
+	if inicios != 1 {
+		t.Errorf("%d mensajes de inicio, se esperaba 1", inicios)
+	}
+	if n := len(sectoresEmitidos(mensajes)); n != sectores*vueltas {
+		t.Errorf("%d registros de sector, se esperaban %d", n, sectores*vueltas)
+	}
+	if resumenes != 1 {
+		t.Errorf("%d resúmenes, se esperaba 1", resumenes)
+	}
+	if ultimo := mensajes[len(mensajes)-1]; ultimo.Tipo != "finalizado" {
+		t.Errorf("el último mensaje es %q, se esperaba finalizado", ultimo.Tipo)
+	}
+}
+
+// parametrosOpenMPPrueba son parámetros OpenMP deterministas y sin pausas
+func parametrosOpenMPPrueba(autos, vueltas, hilos int) ParametrosOpenMP {
+	p := parametrosOpenMPPorDefecto()
+	p.Autos, p.Vueltas, p.Hilos, p.Retardo = autos, vueltas, hilos, 0
+	semilla := semillaPrueba
+	p.Semilla = &semilla
+	return p
+}
+
+func resumenOpenMP(t *testing.T, p ParametrosOpenMP) ResumenOpenMP {
+	t.Helper()
+	resumen, ok := ejecutarSincronico(context.Background(), func(ctx context.Context, enviar chan MensajeWS) {
+		correrOpenMP(ctx, p, nil, enviar)
+	}).(ResumenOpenMP)
+	if !ok {
+		t.Fatalf("la corrida OpenMP no envió un resumen")
+	}
+	return resumen
+}
+
+func TestCorrerOpenMPJuntaLosResultadosDeCadaAuto(t *testing.T) {
+	const autos, vueltas = 8, 20
+	resumen := resumenOpenMP(t, parametrosOpenMPPrueba(autos, vueltas, autos))
+	if len(resumen.MejorPorAuto) != autos {
+		t.Fatalf("%d resultados, se esperaban %d", len(resumen.MejorPorAuto), autos)
+	}
+	for i, r := range resumen.MejorPorAuto {
+		if r.AutoID != i+1 || r.CantidadVueltas != vueltas || len(r.Vueltas) != vueltas {
+			t.Errorf("resultado %d: auto %d con %d vueltas (%d tiempos), se esperaban el auto %d y %d vueltas", i, r.AutoID, r.CantidadVueltas, len(r.Vueltas), i+1, vueltas)
+		}
+	}
+}
+
+func TestCorrerOpenMPNoDependeDeLosHilos(t *testing.T) {
+	base := parametrosOpenMPPrueba(6, 10, 1)
+	base.SafetyCarProb, base.ProbAbandono, base.PitCada = 0.2, 0.05, 4
+	esperado := resumenOpenMP(t, base)
+	for _, hilos := range []int{0, 2, 3, 6} {
+		p := base
+		p.Hilos = hilos
+		if obtenido := resumenOpenMP(t, p); !reflect.DeepEqual(obtenido, esperado) {
+			t.Errorf("con %d hilos el resumen cambia:\n%+v\nse esperaba:\n%+v", hilos, obtenido, esperado)
+		}
+	}
+}
+
+// servidorWS levanta wsHandler en un servidor de prueba y devuelve su URL ws://
+func servidorWS(t *testing.T) string {
+	t.Helper()
+	servidor := httptest.NewServer(http.HandlerFunc(wsHandler))
+	t.Cleanup(servidor.Close)
+	return "ws" + strings.TrimPrefix(servidor.URL, "http")
+}
+
+// conectarWS abre una conexión al servidor de prueba pidiendo protocoloV1;
+// se cierra al terminar el test
+func conectarWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	marcador := websocket.Dialer{HandshakeTimeout: 5 * time.Second, Subprotocols: []string{protocoloV1}}
+	conn, _, err := marcador.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("conectar a %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// leerHastaFinalizado lee mensajes hasta el finalizado del tópico indicado
+func leerHastaFinalizado(t *testing.T, conn *websocket.Conn, topico string) []MensajeWS {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var mensajes []MensajeWS
+	for {
+		var msg MensajeWS
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("leyendo hasta el finalizado de %s: %v; mensajes: %+v", topico, err, mensajes)
+		}
+		mensajes = append(mensajes, msg)
+		if msg.Tipo == "finalizado" && msg.Topico == topico {
+			return mensajes
+		}
+	}
+}
+
+func TestWebSocketSectoresInvalidos(t *testing.T) {
+	conn := conectarWS(t, servidorWS(t))
+	if err := conn.WriteJSON(map[string]any{"action": "iniciar_mpi", "sectores": 0, "vueltas": 2}); err != nil {
+		t.Fatal(err)
+	}
+	comprobarErrorYFinalizado(t, leerHastaFinalizado(t, conn, "mpi"), "mpi")
+}
+
+func TestWebSocketCierraComandosDemasiadoGrandes(t *testing.T) {
+	url := servidorWS(t)
+	for _, tamanio := range []int{maxTamanioComando + 1, limiteLecturaWS + 1} {
+		conn := conectarWS(t, url)
+		// El servidor cierra sin leer el resto del frame, así que la escritura
+		// puede fallar con la conexión ya cortada; lo que importa es el cierre
+		relleno := strings.Repeat("x", tamanio)
+		conn.WriteJSON(map[string]any{"action": "iniciar_mpi", "sectores": 2, "vueltas": 1, "nombre": relleno})
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			var msg MensajeWS
+			err := conn.ReadJSON(&msg)
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+					t.Errorf("%d bytes: se esperaba el cierre %d, llegó %v", tamanio, websocket.CloseMessageTooBig, err)
+				}
+				break
+			}
+			if msg.Tipo == "inicio" {
+				t.Errorf("%d bytes: el comando inició una corrida", tamanio)
+			}
+		}
+		if activas := corridasActivas.instantanea(); len(activas) != 0 {
+			t.Errorf("%d bytes: %d corridas activas, se esperaba ninguna", tamanio, len(activas))
+		}
+	}
+}
+
+// esperarSinCorridas espera a que el registro de corridas quede vacío y a
+// que vuelva a haber como mucho base goroutines
+func esperarSinCorridas(t *testing.T, base int) {
+	t.Helper()
+	plazo := time.Now().Add(5 * time.Second)
+	for len(corridasActivas.instantanea()) > 0 || runtime.NumGoroutine() > base {
+		if time.Now().After(plazo) {
+			t.Fatalf("quedaron %d corridas activas y %d goroutines (había %d)", len(corridasActivas.instantanea()), runtime.NumGoroutine(), base)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketDesconexionesAMitadDeCorrida(t *testing.T) {
+	url := servidorWS(t)
+	base := runtime.NumGoroutine()
+	comandos := []map[string]any{
+		{"action": "iniciar_mpi", "sectores": 5, "vueltas": 100, "delay_ms": 2},
+		{"action": "iniciar_openmp", "autos": 6, "vueltas": 100, "delay_ms": 2},
+		{"action": "iniciar_anillo", "nodos": 6, "vueltas_anillo": 0, "duracion_seg": 30, "delay_ms": 2},
+	}
+	const conexiones = 6
+	for i := 0; i < conexiones; i++ {
+		marcador := websocket.Dialer{HandshakeTimeout: 5 * time.Second, Subprotocols: []string{protocoloV1}}
+		conn, _, err := marcador.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range comandos {
+			if err := conn.WriteJSON(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Se espera a que las tres simulaciones estén corriendo
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		corriendo := map[string]bool{}
+		for len(corriendo) < len(comandos) {
+			var msg MensajeWS
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("conexión %d: %v", i, err)
+			}
+			if msg.Tipo == "registro" && msg.RunID != "" && strings.HasPrefix(msg.Texto, "Iniciando") {
+				corriendo[msg.Topico] = true
+			}
+		}
+		// La mitad detiene sus corridas y la otra mitad corta la conexión sin avisar
+		if i%2 == 0 {
+			if err := conn.WriteJSON(map[string]any{"action": "detener", "topico": ""}); err != nil {
+				t.Fatal(err)
+			}
+			for finalizados := 0; finalizados < len(comandos); {
+				var msg MensajeWS
+				if err := conn.ReadJSON(&msg); err != nil {
+					t.Fatalf("conexión %d: %v", i, err)
+				}
+				if msg.Tipo == "finalizado" && corriendo[msg.Topico] {
+					finalizados++
+				}
+			}
+		}
+		conn.Close()
+	}
+	esperarSinCorridas(t, base)
+}
+
+func TestTiempoAleatorioQuedaEnElRango(t *testing.T) {
+	aleatorio := rand.New(rand.NewSource(semillaPrueba))
+	rangos := [][2]float64{{tiempoMinSector, tiempoMaxSector}, {tiempoMinVuelta, tiempoMaxVuelta}, {1.005, 1.02}, {0.1, 0.2}}
+	for _, r := range rangos {
+		for i := 0; i < 10000; i++ {
+			tiempo := tiempoAleatorio(aleatorio, r[0], r[1])
+			if tiempo < r[0] || tiempo >= r[1] {
+				t.Fatalf("tiempoAleatorio(%g, %g) = %g, fuera de [min, max)", r[0], r[1], tiempo)
+			}
+			if centesimas := tiempo * 100; math.Abs(centesimas-math.Round(centesimas)) > 1e-9 {
+				t.Fatalf("tiempoAleatorio(%g, %g) = %g, no tiene precisión de centésimas", r[0], r[1], tiempo)
+			}
+		}
+	}
+}
+
+func TestValidarRechazaRangosDeTiempoInvalidos(t *testing.T) {
+	rangos := [][2]float64{{0, 10}, {-1, 10}, {10, 0}, {10, 10}, {20, 10}}
+	for _, r := range rangos {
+		pm := parametrosMPIPrueba(3, 2)
+		pm.TiempoMin, pm.TiempoMax = r[0], r[1]
+		if pm.validar() == nil {
+			t.Errorf("MPI aceptó tiempo_min %g y tiempo_max %g", r[0], r[1])
+		}
+		po := parametrosOpenMPPrueba(2, 2, 1)
+		po.TiempoMin, po.TiempoMax = r[0], r[1]
+		if po.validar() == nil {
+			t.Errorf("OpenMP aceptó tiempo_min %g y tiempo_max %g", r[0], r[1])
+		}
+	}
+	if err := parametrosMPIPrueba(3, 2).validar(); err != nil {
+		t.Errorf("MPI rechazó los valores por defecto: %v", err)
+	}
+	if err := parametrosOpenMPPrueba(2, 2, 1).validar(); err != nil {
+		t.Errorf("OpenMP rechazó los valores por defecto: %v", err)
+	}
+}
+
+func TestClasificarOrdenaYDesempataPorPromedio(t *testing.T) {
+	resultados := []ResultadoOpenMP{
+		{AutoID: 1, MejorVuelta: 80.5, PromedioVuelta: 82},
+		{AutoID: 2, MejorVuelta: 0, Abandono: true},
+		{AutoID: 3, MejorVuelta: 79.9, PromedioVuelta: 85},
+		{AutoID: 4, MejorVuelta: 80.5, PromedioVuelta: 81},
+		{AutoID: 5, MejorVuelta: 81, PromedioVuelta: 81},
+	}
+	var orden []int
+	for _, r := range clasificar(resultados) {
+		orden = append(orden, r.AutoID)
+	}
+	if esperado := []int{3, 4, 1, 5, 2}; !reflect.DeepEqual(orden, esperado) {
+		t.Errorf("orden %v, se esperaba %v", orden, esperado)
+	}
+	if resultados[0].AutoID != 1 || resultados[2].AutoID != 3 {
+		t.Errorf("clasificar modificó los resultados recibidos: %+v", resultados)
+	}
+}
+
+func TestCorrerOpenMPRespetaLosHilos(t *testing.T) {
+	const autos, vueltas, hilos = 8, 5, 3
+	p := parametrosOpenMPPrueba(autos, vueltas, hilos)
+	p.Retardo = time.Millisecond
+	mensajes := correrHastaElFinal(func(ctx context.Context, enviar chan MensajeWS) {
+		correrOpenMP(ctx, p, nil, enviar)
+	})
+	// Un auto anuncia su hilo después de tomarlo y envía su última vuelta
+	// antes de devolverlo, así que entre esos dos mensajes lo tiene seguro
+	hiloDe := map[int]int{}
+	terminado := map[int]bool{}
+	enCurso := 0
+	for _, msg := range mensajes {
+		var auto, hilo int
+		if _, err := fmt.Sscanf(msg.Texto, "Auto %d corre en el hilo %d", &auto, &hilo); err == nil {
+			if hilo < 1 || hilo > hilos {
+				t.Fatalf("auto %d en el hilo %d, solo hay %d", auto, hilo, hilos)
+			}
+			for otro, h := range hiloDe {
+				if h == hilo && !terminado[otro] {
+					t.Fatalf("autos %d y %d usan el hilo %d a la vez", otro, auto, hilo)
+				}
+			}
+			hiloDe[auto] = hilo
+			if enCurso++; enCurso > hilos {
+				t.Fatalf("%d autos corriendo a la vez con %d hilos", enCurso, hilos)
+			}
+		}
+		if v, ok := msg.Obj.(TiempoVueltaAuto); ok && v.Vuelta == vueltas && !terminado[v.Auto] {
+			terminado[v.Auto] = true
+			enCurso--
+		}
+	}
+	if len(hiloDe) != autos || len(terminado) != autos {
+		t.Errorf("%d autos tomaron hilo y %d terminaron, se esperaban %d", len(hiloDe), len(terminado), autos)
+	}
+}
+
+func TestCalcularEstadisticas(t *testing.T) {
+	casos := []struct {
+		tiempos  []float64
+		esperado Estadisticas
+	}{
+		{nil, Estadisticas{}},
+		{[]float64{3.5}, Estadisticas{Cantidad: 1, Minimo: 3.5, Maximo: 3.5, Media: 3.5}},
+		{[]float64{2, 4, 4, 4, 5, 5, 7, 9}, Estadisticas{Cantidad: 8, Minimo: 2, Maximo: 9, Media: 5, Desvio: 2}},
+		{[]float64{1, 2}, Estadisticas{Cantidad: 2, Minimo: 1, Maximo: 2, Media: 1.5, Desvio: 0.5}},
+	}
+	for _, c := range casos {
+		if e := calcularEstadisticas(c.tiempos); e != c.esperado {
+			t.Errorf("calcularEstadisticas(%v) = %+v, se esperaba %+v", c.tiempos, e, c.esperado)
+		}
+	}
+
+	// En una corrida con semilla resume todos los tiempos de sector
+	const sectores, vueltas = 3, 5
+	resumen := resumenMPI(t, mensajesMPI(parametrosMPIPrueba(sectores, vueltas)))
+	var todos []float64
+	for _, vuelta := range resumen.Tiempos {
+		todos = append(todos, vuelta...)
+	}
+	e := resumen.Estadisticas
+	if e.Cantidad != sectores*vueltas || e != calcularEstadisticas(todos) {
+		t.Errorf("estadísticas MPI %+v, se esperaban %+v con %d tiempos", e, calcularEstadisticas(todos), sectores*vueltas)
+	}
+	if e.Minimo < tiempoMinSector || e.Maximo >= tiempoMaxSector || e.Media < e.Minimo || e.Media > e.Maximo {
+		t.Errorf("estadísticas MPI fuera del rango de tiempos: %+v", e)
+	}
+	if otra := resumenMPI(t, mensajesMPI(parametrosMPIPrueba(sectores, vueltas))).Estadisticas; otra != e {
+		t.Errorf("con la misma semilla las estadísticas cambian: %+v y %+v", e, otra)
+	}
+}
+
+func TestFormatearTiempo(t *testing.T) {
+	casos := []struct {
+		seg      float64
+		formato  string
+		esperado string
+	}{
+		{62.5, formatoMinutos, "1:02.500"},
+		{59.9996, formatoMinutos, "1:00.000"},
+		{59.9994, formatoMinutos, "0:59.999"},
+		{0, formatoMinutos, "0:00.000"},
+		{3600.25, formatoMinutos, "60:00.250"},
+		{-62.5, formatoMinutos, "-1:02.500"},
+		{-0.0001, formatoMinutos, "0:00.000"},
+		{62.5, formatoMilisegundos, "62500 ms"},
+		{1.2345, formatoMilisegundos, "1235 ms"},
+		{-0.25, formatoMilisegundos, "-250 ms"},
+		{62.5, formatoSegundos, "62.50 s"},
+		{-1.5, formatoSegundos, "-1.50 s"},
+		{62.5, "", "62.50 s"},
+		{62.5, "horas", "62.50 s"},
+	}
+	for _, c := range casos {
+		if obtenido := formatearTiempo(c.seg, c.formato); obtenido != c.esperado {
+			t.Errorf("formatearTiempo(%g, %q) = %q, se esperaba %q", c.seg, c.formato, obtenido, c.esperado)
+		}
+	}
+}
+
+func TestVueltasInvalidasNoCorren(t *testing.T) {
+	for _, vueltas := range []int{0, -3} {
+		mpi := mensajesMPI(parametrosMPIPrueba(3, vueltas))
+		comprobarErrorYFinalizado(t, mpi, "mpi")
+		openmp := correrHastaElFinal(func(ctx context.Context, enviar chan MensajeWS) {
+			correrOpenMP(ctx, parametrosOpenMPPrueba(3, vueltas, 1), nil, enviar)
+		})
+		comprobarErrorYFinalizado(t, openmp, "openmp")
+		for _, msg := range append(mpi, openmp...) {
+			if msg.Tipo == "error" && !strings.Contains(msg.Error, "vueltas") {
+				t.Errorf("%d vueltas: el error no menciona las vueltas: %s", vueltas, msg.Error)
+			}
+			if msg.Tipo == "registro" && strings.HasPrefix(msg.Texto, "Iniciando") {
+				t.Errorf("%d vueltas: la simulación arrancó igual: %s", vueltas, msg.Texto)
+			}
+		}
+	}
+}
+
+func TestMuestrearRespetaLaDistribucion(t *testing.T) {
+	const min, max, muestras = tiempoMinVuelta, tiempoMaxVuelta, 100000
+	ancho := max - min
+	// Media y desvío esperados de cada distribución en [min, max]
+	esperados := map[string][2]float64{
+		distribucionUniforme:   {(min + max) / 2, ancho / math.Sqrt(12)},
+		distribucionNormal:     {(min + max) / 2, ancho / 6},
+		distribucionTriangular: {(min + max) / 2, ancho / math.Sqrt(24)},
+	}
+	for dist, esperado := range esperados {
+		aleatorio := rand.New(rand.NewSource(semillaPrueba))
+		tiempos := make([]float64, muestras)
+		for i := range tiempos {
+			tiempos[i] = muestrear(dist, min, max, aleatorio)
+			if tiempos[i] < min || tiempos[i] > max {
+				t.Fatalf("%s: %g fuera de [%g, %g]", dist, tiempos[i], min, max)
+			}
+		}
+		e := calcularEstadisticas(tiempos)
+		if math.Abs(e.Media-esperado[0]) > 0.1 {
+			t.Errorf("%s: media %.2f, se esperaba cerca de %.2f", dist, e.Media, esperado[0])
+		}
+		if math.Abs(e.Desvio-esperado[1]) > 0.05*esperado[1] {
+			t.Errorf("%s: desvío %.2f, se esperaba cerca de %.2f", dist, e.Desvio, esperado[1])
+		}
+	}
+}
+
+func TestCompararNoGuardaUltimoOpenMP(t *testing.T) {
+	borrarUltimoOpenMP()
+	t.Cleanup(borrarUltimoOpenMP)
+	pa, pb := parametrosOpenMPPrueba(2, 2, 1), parametrosOpenMPPrueba(3, 2, 1)
+	pa.Topico, pb.Topico = "openmp_a", "openmp_b"
+	correrHastaElFinal(func(ctx context.Context, enviar chan MensajeWS) {
+		correrComparacion(ctx, pa, pb, nil, enviar)
+	})
+	if ultimo := obtenerUltimoOpenMP(); ultimo != nil {
+		t.Fatalf("la comparación guardó una corrida de %d autos como la última OpenMP", len(ultimo.MejorPorAuto))
+	}
+	esperado := resumenOpenMP(t, parametrosOpenMPPrueba(4, 2, 1))
+	if ultimo := obtenerUltimoOpenMP(); ultimo == nil || !reflect.DeepEqual(*ultimo, esperado) {
+		t.Errorf("una carrera OpenMP no quedó guardada como la última")
+	}
+}
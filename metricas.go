@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// -------------------- Métricas --------------------
+
+// limitesDuracion son los límites superiores (s) de las cubetas del
+// histograma de duración de las simulaciones; la última es +Inf.
+var limitesDuracion = []float64{1, 5, 15, 30, 60, 120, 300, math.Inf(1)}
+
+// histograma acumula duraciones en cubetas no acumulativas; la suma
+// acumulativa que pide el formato de Prometheus se arma al exponerlo.
+type histograma struct {
+	mu       sync.Mutex
+	cubetas  []int64 // cubetas[i]: observaciones <= limitesDuracion[i] y > la anterior
+	suma     float64
+	cantidad int64
+}
+
+func nuevoHistograma() *histograma {
+	return &histograma{cubetas: make([]int64, len(limitesDuracion))}
+}
+
+// observar registra una duración en segundos
+func (h *histograma) observar(segundos float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, limite := range limitesDuracion {
+		if segundos <= limite {
+			h.cubetas[i]++
+			break
+		}
+	}
+	h.suma += segundos
+	h.cantidad++
+}
+
+// metricasSimulacion son los contadores de un tipo de simulación (ver
+// simulacionesMedidas)
+type metricasSimulacion struct {
+	corridas atomic.Int64
+	duracion *histograma
+}
+
+// simulacionesMedidas son los tipos de simulación con métricas propias, en el
+// orden en que se exponen. Una sesión cuenta una sola vez aunque corra
+// OpenMP y MPI adentro (ver simulacionDeAccion).
+var simulacionesMedidas = []string{"mpi", "openmp", "clasificacion", "sesion", "anillo", "comparar", "reproduccion"}
+
+// metricas agrupa las métricas de todas las simulaciones instrumentadas
+var metricas = struct {
+	activas          atomic.Int64
+	mensajesOmitidos atomic.Int64 // eventos descartados por clientes lentos (ver colaSalida)
+	simulaciones     map[string]*metricasSimulacion
+	ordenTopicos     []string // orden fijo de exposición
+}{
+	simulaciones: func() map[string]*metricasSimulacion {
+		simulaciones := map[string]*metricasSimulacion{}
+		for _, s := range simulacionesMedidas {
+			simulaciones[s] = &metricasSimulacion{duracion: nuevoHistograma()}
+		}
+		return simulaciones
+	}(),
+	ordenTopicos: simulacionesMedidas,
+}
+
+// simulacionDeAccion devuelve el tipo de simulación que lanza accion en las
+// métricas: iniciar_X es X, comparar_openmp es "comparar" y reproducir
+// "reproduccion"
+func simulacionDeAccion(accion string) string {
+	switch accion {
+	case "comparar_openmp":
+		return "comparar"
+	case "reproducir":
+		return "reproduccion"
+	}
+	return strings.TrimPrefix(accion, "iniciar_")
+}
+
+// medirCorrida cuenta una corrida de topico (uno de simulacionesMedidas) y la
+// marca como activa. La función devuelta la da por terminada y registra su
+// duración; se usa con defer:
+//
+//	defer medirCorrida("mpi")()
+func medirCorrida(topico string) func() {
+	m := metricas.simulaciones[topico]
+	m.corridas.Add(1)
+	metricas.activas.Add(1)
+	inicio := time.Now()
+	return func() {
+		metricas.activas.Add(-1)
+		m.duracion.observar(time.Since(inicio).Seconds())
+	}
+}
+
+// metricsHandler atiende GET /metrics en el formato de texto de Prometheus
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		responderJSON(w, http.StatusMethodNotAllowed, ErrorAPI{Error: "método no permitido, usar GET"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP formula_sim_corridas_total Simulaciones iniciadas por tipo.")
+	fmt.Fprintln(w, "# TYPE formula_sim_corridas_total counter")
+	for _, topico := range metricas.ordenTopicos {
+		fmt.Fprintf(w, "formula_sim_corridas_total{simulacion=%q} %d\n", topico, metricas.simulaciones[topico].corridas.Load())
+	}
+
+	fmt.Fprintln(w, "# HELP formula_sim_simulaciones_activas Simulaciones en curso.")
+	fmt.Fprintln(w, "# TYPE formula_sim_simulaciones_activas gauge")
+	fmt.Fprintf(w, "formula_sim_simulaciones_activas %d\n", metricas.activas.Load())
+
+	fmt.Fprintln(w, "# HELP formula_sim_mensajes_omitidos_total Mensajes omitidos por clientes WebSocket lentos.")
+	fmt.Fprintln(w, "# TYPE formula_sim_mensajes_omitidos_total counter")
+	fmt.Fprintf(w, "formula_sim_mensajes_omitidos_total %d\n", metricas.mensajesOmitidos.Load())
+
+	fmt.Fprintln(w, "# HELP formula_sim_duracion_segundos Duración de las simulaciones terminadas.")
+	fmt.Fprintln(w, "# TYPE formula_sim_duracion_segundos histogram")
+	for _, topico := range metricas.ordenTopicos {
+		h := metricas.simulaciones[topico].duracion
+		h.mu.Lock()
+		acumulado := int64(0)
+		for i, limite := range limitesDuracion {
+			acumulado += h.cubetas[i]
+			le := "+Inf"
+			if !math.IsInf(limite, 1) {
+				le = strconv.FormatFloat(limite, 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "formula_sim_duracion_segundos_bucket{simulacion=%q,le=%q} %d\n", topico, le, acumulado)
+		}
+		fmt.Fprintf(w, "formula_sim_duracion_segundos_sum{simulacion=%q} %g\n", topico, h.suma)
+		fmt.Fprintf(w, "formula_sim_duracion_segundos_count{simulacion=%q} %d\n", topico, h.cantidad)
+		h.mu.Unlock()
+	}
+}
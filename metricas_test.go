@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+// corridasMedidas devuelve cuántas corridas lleva contadas cada tipo de simulación
+func corridasMedidas() map[string]int64 {
+	corridas := map[string]int64{}
+	for _, s := range simulacionesMedidas {
+		corridas[s] = metricas.simulaciones[s].corridas.Load()
+	}
+	return corridas
+}
+
+func TestMetricasCuentanCadaCorridaUnaVez(t *testing.T) {
+	casos := []struct {
+		comando    string
+		topico     string
+		simulacion string
+	}{
+		{`{"action":"iniciar_mpi","sectores":2,"vueltas":1,"delay_ms":0}`, "mpi", "mpi"},
+		{`{"action":"iniciar_openmp","autos":2,"vueltas":1,"delay_ms":0}`, "openmp", "openmp"},
+		{`{"action":"iniciar_clasificacion","autos":2,"vueltas":1,"delay_ms":0}`, "openmp", "clasificacion"},
+		{`{"action":"iniciar_sesion","autos":2,"sectores":2,"vueltas":1,"delay_ms":0}`, "sesion", "sesion"},
+		{`{"action":"iniciar_anillo","nodos":2,"vueltas_anillo":1,"delay_ms":0}`, "anillo", "anillo"},
+		{`{"action":"comparar_openmp","a":{"autos":2,"vueltas":1,"delay_ms":0},"b":{"autos":3,"vueltas":1,"delay_ms":0}}`, "comparar", "comparar"},
+	}
+	for _, c := range casos {
+		s, enviar := sesionPrueba(t)
+		antes := corridasMedidas()
+		s.procesarComando([]byte(c.comando))
+		esperarFinalizado(t, enviar, c.topico)
+		s.detenerTodas() // las métricas se cierran después del finalizado
+		despues := corridasMedidas()
+		for _, simulacion := range simulacionesMedidas {
+			esperado := int64(0)
+			if simulacion == c.simulacion {
+				esperado = 1
+			}
+			if delta := despues[simulacion] - antes[simulacion]; delta != esperado {
+				t.Errorf("%s: %d corridas de %s, se esperaban %d", c.comando, delta, simulacion, esperado)
+			}
+		}
+	}
+	if activas := metricas.activas.Load(); activas != 0 {
+		t.Errorf("%d simulaciones activas al terminar, se esperaba ninguna", activas)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// -------------------- Codificación MessagePack --------------------
+
+/*
+Codificación compacta opcional de los mensajes (/ws?fmt=msgpack). Para no
+sumar dependencias se implementa solo la parte de MessagePack que hace falta:
+el mensaje se pasa primero por JSON, así los nombres de campo y omitempty son
+los mismos en ambos formatos, y el resultado genérico (mapas, listas, números,
+textos, booleanos y null) se escribe en MessagePack.
+*/
+
+// codificarMsgpack devuelve v codificado en MessagePack
+func codificarMsgpack(v any) ([]byte, error) {
+	datos, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decodificador := json.NewDecoder(bytes.NewReader(datos))
+	decodificador.UseNumber()
+	var generico any
+	if err := decodificador.Decode(&generico); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := escribirMsgpack(&b, generico); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// escribirMsgpack escribe un valor decodificado de JSON (con UseNumber)
+func escribirMsgpack(b *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteByte(0xc0)
+	case bool:
+		if v {
+			b.WriteByte(0xc3)
+		} else {
+			b.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			escribirEnteroMsgpack(b, n)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		b.WriteByte(0xcb)
+		binary.Write(b, binary.BigEndian, math.Float64bits(f))
+	case string:
+		escribirLongitud(b, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		b.WriteString(v)
+	case []any:
+		escribirLongitud(b, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, elemento := range v {
+			if err := escribirMsgpack(b, elemento); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		// Claves ordenadas para que la misma entrada produzca los mismos bytes
+		claves := make([]string, 0, len(v))
+		for k := range v {
+			claves = append(claves, k)
+		}
+		sort.Strings(claves)
+		escribirLongitud(b, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		for _, k := range claves {
+			escribirMsgpack(b, k)
+			if err := escribirMsgpack(b, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: tipo no soportado %T", v)
+	}
+	return nil
+}
+
+// escribirEnteroMsgpack usa la representación más corta para n
+func escribirEnteroMsgpack(b *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 127:
+		b.WriteByte(byte(n)) // positive fixint
+	case n < 0 && n >= -32:
+		b.WriteByte(byte(int8(n))) // negative fixint
+	case n >= 0 && n <= math.MaxUint8:
+		b.Write([]byte{0xcc, byte(n)})
+	case n >= 0 && n <= math.MaxUint16:
+		b.WriteByte(0xcd)
+		binary.Write(b, binary.BigEndian, uint16(n))
+	case n >= 0 && n <= math.MaxUint32:
+		b.WriteByte(0xce)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	case n >= 0:
+		b.WriteByte(0xcf)
+		binary.Write(b, binary.BigEndian, uint64(n))
+	case n >= math.MinInt8:
+		b.Write([]byte{0xd0, byte(int8(n))})
+	case n >= math.MinInt16:
+		b.WriteByte(0xd1)
+		binary.Write(b, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		b.WriteByte(0xd2)
+		binary.Write(b, binary.BigEndian, int32(n))
+	default:
+		b.WriteByte(0xd3)
+		binary.Write(b, binary.BigEndian, n)
+	}
+}
+
+// escribirLongitud escribe la cabecera de un texto, lista o mapa de n
+// elementos: la forma "fix" (fijo|n) si n < maxFijo y si no la de 8 bits (si el
+// tipo la tiene), 16 o 32 bits.
+func escribirLongitud(b *bytes.Buffer, n int, fijo byte, maxFijo int, c8, c16, c32 byte) {
+	switch {
+	case n < maxFijo:
+		b.WriteByte(fijo | byte(n))
+	case c8 != 0 && n <= math.MaxUint8:
+		b.Write([]byte{c8, byte(n)})
+	case n <= math.MaxUint16:
+		b.WriteByte(c16)
+		binary.Write(b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(c32)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+}
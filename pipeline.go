@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// -------------------- MPI en pipeline --------------------
+
+// correrPipelineMPI recorre los sectores como etapas de un pipeline: cada
+// sector es una goroutine que atiende las vueltas en orden y le pasa cada
+// una a la etapa siguiente por un canal, así el sector N de la vuelta V+1
+// corre mientras el sector N+1 atiende la vuelta V. cerrar se llama desde la
+// goroutine que llamó con cada vuelta que completa la última etapa.
+// Solo entran las primeras vueltas; la etapa donde hay bandera roja no
+// pasa la vuelta a la siguiente, así que esa vuelta nunca se cierra.
+// Si se cancela ctx las etapas se retiran y no se cierran más vueltas.
+func correrPipelineMPI(ctx context.Context, c corridaMPI, vueltas int, pausa *compuerta, enviar chan MensajeWS, cerrar func(v int)) []EtapaPipeline {
+	p := c.p
+	pendientes := make(chan int, vueltas)
+	for v := 1; v <= vueltas; v++ {
+		pendientes <- v
+	}
+	close(pendientes)
+
+	// Cada etapa escribe solo su posición del slice
+	etapas := make([]EtapaPipeline, p.Sectores)
+	var wg sync.WaitGroup
+	var entrada <-chan int = pendientes
+	for s := 1; s <= p.Sectores; s++ {
+		salida := make(chan int)
+		wg.Add(1)
+		go func(s int, entrada <-chan int, salida chan<- int) {
+			defer wg.Done()
+			defer close(salida)
+			etapa := &etapas[s-1]
+			*etapa = EtapaPipeline{Sector: s, Nombre: c.nombres[s-1]}
+			var inicio, fin time.Time
+			for v := range entrada {
+				if etapa.Vueltas == 0 {
+					inicio = time.Now()
+				}
+				if s == 1 {
+					enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("=== Vuelta %d ===", v)}
+				}
+				if c.banderas[v-1] == s {
+					enviar <- c.registroBandera(v)
+					continue
+				}
+				enviar <- c.pasoSector(v, s)
+				if !esperar(ctx, p.Retardo) || !pausa.pasar(ctx) {
+					return
+				}
+				informarProgreso(ctx, v, s)
+				select {
+				case salida <- v:
+				case <-ctx.Done():
+					return
+				}
+				etapa.Vueltas++
+				fin = time.Now()
+			}
+			duracion := fin.Sub(inicio)
+			etapa.DuracionMs = redondear(float64(duracion) / float64(time.Millisecond))
+			if duracion > 0 {
+				etapa.VueltasPorSegundo = redondear(float64(etapa.Vueltas) / duracion.Seconds())
+			}
+		}(s, entrada, salida)
+		entrada = salida
+	}
+
+	for v := range entrada {
+		cerrar(v)
+	}
+	wg.Wait()
+	return etapas
+}
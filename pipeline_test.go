@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// resumenMPI devuelve el resumen de la corrida entre mensajes
+func resumenMPI(t *testing.T, mensajes []MensajeWS) ResumenMPI {
+	t.Helper()
+	for _, msg := range mensajes {
+		if r, ok := msg.Obj.(ResumenMPI); ok && msg.Tipo == "resumen" {
+			return r
+		}
+	}
+	t.Fatalf("la corrida MPI no envió un resumen")
+	return ResumenMPI{}
+}
+
+func TestPipelineCorreLoMismoQueSecuencial(t *testing.T) {
+	const sectores, vueltas = 4, 6
+	secuencial := parametrosMPIPrueba(sectores, vueltas)
+	pipeline := secuencial
+	pipeline.Pipeline = true
+	pipeline.Retardo = time.Millisecond // para que las etapas se solapen
+	mensajesSecuencial, mensajesPipeline := mensajesMPI(secuencial), mensajesMPI(pipeline)
+
+	// Cada par sector-vuelta se corre una sola vez, con el tiempo sorteado
+	// para él, y cada etapa atiende las vueltas en orden
+	tiempos := map[[2]int]float64{}
+	for _, s := range sectoresEmitidos(mensajesSecuencial) {
+		tiempos[[2]int{s.Sector, s.Vuelta}] = s.Tiempo
+	}
+	vistos := map[[2]int]bool{}
+	ultimaVuelta := map[int]int{}
+	for _, s := range sectoresEmitidos(mensajesPipeline) {
+		clave := [2]int{s.Sector, s.Vuelta}
+		if vistos[clave] {
+			t.Errorf("sector %d de la vuelta %d se corrió dos veces", s.Sector, s.Vuelta)
+		}
+		vistos[clave] = true
+		if s.Tiempo != tiempos[clave] {
+			t.Errorf("sector %d de la vuelta %d: %.2f en pipeline, %.2f secuencial", s.Sector, s.Vuelta, s.Tiempo, tiempos[clave])
+		}
+		if s.Vuelta <= ultimaVuelta[s.Sector] {
+			t.Errorf("el sector %d atendió la vuelta %d después de la %d", s.Sector, s.Vuelta, ultimaVuelta[s.Sector])
+		}
+		ultimaVuelta[s.Sector] = s.Vuelta
+	}
+	if len(vistos) != sectores*vueltas {
+		t.Errorf("%d pares sector-vuelta, se esperaban %d", len(vistos), sectores*vueltas)
+	}
+
+	a, b := resumenMPI(t, mensajesSecuencial), resumenMPI(t, mensajesPipeline)
+	if !reflect.DeepEqual(a.Tiempos, b.Tiempos) || !reflect.DeepEqual(a.Vueltas, b.Vueltas) || a.TiempoTotal != b.TiempoTotal {
+		t.Errorf("el resumen en pipeline no coincide con el secuencial:\n%+v\n%+v", b, a)
+	}
+	if len(b.Etapas) != sectores {
+		t.Errorf("%d etapas, se esperaban %d", len(b.Etapas), sectores)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "formula-sim/protocolo"
+
+// -------------------- Tipos compartidos con el cliente --------------------
+
+// Los mensajes y el contenido estructurado de cada uno viven en el paquete
+// protocolo para que el paquete client los decodifique con los mismos tipos;
+// acá se los nombra como siempre.
+type (
+	MensajeWS     = protocolo.MensajeWS
+	InicioCorrida = protocolo.InicioCorrida
+	FinCorrida    = protocolo.FinCorrida
+
+	EstadoCorrida   = protocolo.EstadoCorrida
+	ProgresoCorrida = protocolo.ProgresoCorrida
+
+	ResumenMPI    = protocolo.ResumenMPI
+	TiempoSector  = protocolo.TiempoSector
+	ColumnaSector = protocolo.ColumnaSector
+	AcumuladoMPI  = protocolo.AcumuladoMPI
+	VueltaMPI     = protocolo.VueltaMPI
+	EtapaPipeline = protocolo.EtapaPipeline
+	Estadisticas  = protocolo.Estadisticas
+
+	ResultadoOpenMP   = protocolo.ResultadoOpenMP
+	Stint             = protocolo.Stint
+	TiempoVueltaAuto  = protocolo.TiempoVueltaAuto
+	ResumenOpenMP     = protocolo.ResumenOpenMP
+	PosicionCarrera   = protocolo.PosicionCarrera
+	PuntosAuto        = protocolo.PuntosAuto
+	PosicionesVuelta  = protocolo.PosicionesVuelta
+	CambioPosicion    = protocolo.CambioPosicion
+	DeltaPosiciones   = protocolo.DeltaPosiciones
+	ComparacionOpenMP = protocolo.ComparacionOpenMP
+
+	ResultadoClasificacion = protocolo.ResultadoClasificacion
+	ResumenClasificacion   = protocolo.ResumenClasificacion
+	ResumenCombinado       = protocolo.ResumenCombinado
+
+	ResumenAnillo  = protocolo.ResumenAnillo
+	LatenciaVuelta = protocolo.LatenciaVuelta
+)
@@ -0,0 +1,74 @@
+// Package protocolo define los mensajes que el servidor envía por WebSocket y
+// el contenido estructurado (Obj) de cada uno. Lo usan el servidor y el
+// paquete client, así los dos decodifican el mismo formato.
+package protocolo
+
+import "time"
+
+// V1 es la versión del formato de mensajes que se negocia como subprotocolo
+// del WebSocket (Sec-WebSocket-Protocol)
+const V1 = "f1sim.v1"
+
+// MensajeWS representa cualquier mensaje enviado al cliente vía WebSocket
+type MensajeWS struct {
+	Tipo   string `json:"tipo"`             // "registro", "error", "resumen", "finalizado" (OpenMP también "posiciones" y "leaderboard_delta")
+	Topico string `json:"topico,omitempty"` // "mpi", "openmp" o "anillo"
+	Texto  string `json:"texto,omitempty"`  // texto del mensaje
+	Obj    any    `json:"obj,omitempty"`    // datos estructurados (p. ej. el resumen)
+	RunID  string `json:"run_id,omitempty"` // identifica la corrida que generó el mensaje
+
+	// MsEpoch son los milisegundos transcurridos desde el inicio de la corrida
+	MsEpoch int64 `json:"ms_epoch"`
+
+	// Indice y Total numeran las partes de un resumen fragmentado
+	// ("resumen_parte" y "resumen_fin")
+	Indice int `json:"indice,omitempty"`
+	Total  int `json:"total,omitempty"`
+
+	// Error es el motivo de un mensaje de tipo "error", sin el prefijo "Error: "
+	// que lleva Texto
+	Error string `json:"error,omitempty"`
+}
+
+// InicioCorrida es el contenido estructurado del mensaje "inicio", que se
+// envía antes del primer registro de cada simulación con lo que realmente va
+// a correr: los parámetros ya combinados con los valores por defecto, que
+// pueden no coincidir con los pedidos.
+type InicioCorrida struct {
+	RunID           string    `json:"run_id"`
+	Conexion        string    `json:"conexion"` // id de la conexión en los logs del servidor, junto con run_id
+	Version         string    `json:"version"`  // del formato de mensajes de la conexión
+	Accion          string    `json:"accion"`
+	Semilla         *int64    `json:"semilla,omitempty"` // la usada, aunque el cliente no la haya indicado
+	FactorVelocidad float64   `json:"factor_velocidad"`  // aplicado a las pausas (1 = sin acelerar)
+	Parametros      any       `json:"parametros,omitempty"`
+	HoraServidor    time.Time `json:"hora_servidor"`
+}
+
+// FinCorrida es el contenido estructurado del mensaje "finalizado" de cada
+// simulación, terminada o detenida
+type FinCorrida struct {
+	RunID      string `json:"run_id"`
+	DuracionMs int64  `json:"duracion_ms"` // tiempo real desde que arrancó la corrida
+	Eventos    int    `json:"eventos"`     // mensajes enviados al cliente, este incluido
+}
+
+// EstadoCorrida describe una simulación en curso en la respuesta al comando
+// "estado", para que un cliente que se reconecta sepa qué está corriendo
+type EstadoCorrida struct {
+	RunID          string          `json:"run_id"`
+	Conexion       string          `json:"conexion,omitempty"` // conexión que la lanzó (vacío en /api/stream)
+	Topico         string          `json:"topico"`
+	Accion         string          `json:"accion"`
+	Parametros     any             `json:"parametros,omitempty"`
+	Inicio         time.Time       `json:"inicio"`
+	TranscurridoMs int64           `json:"transcurrido_ms"`
+	Progreso       ProgresoCorrida `json:"progreso"`
+}
+
+// ProgresoCorrida es hasta dónde llegó una simulación en curso
+type ProgresoCorrida struct {
+	Vuelta int `json:"vuelta"`           // vuelta más avanzada (en OpenMP, la del auto que va adelante)
+	Sector int `json:"sector,omitempty"` // último sector de esa vuelta (solo MPI)
+	Pasos  int `json:"pasos"`            // sectores, vueltas de auto o vueltas del token completados
+}
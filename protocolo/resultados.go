@@ -0,0 +1,268 @@
+package protocolo
+
+// -------------------- MPI --------------------
+
+// ResumenMPI es el contenido estructurado del mensaje "resumen" de MPI
+type ResumenMPI struct {
+	Sectores    int         `json:"sectores"`
+	Tiempos     [][]float64 `json:"tiempos"` // tiempos[v][s]: sector s+1 de la vuelta v+1 (vacía si hubo bandera roja)
+	TiempoTotal float64     `json:"tiempo_total"`
+
+	DegradacionTotal float64 `json:"degradacion_total"` // segundos sumados por degradación en toda la sesión
+	Clima            string  `json:"clima"`
+
+	Vueltas     []VueltaMPI `json:"vueltas"`      // total de cada vuelta completa
+	MejorVuelta VueltaMPI   `json:"mejor_vuelta"` // vuelta completa más rápida
+
+	// VueltaIdeal suma el mejor tiempo de cada sector entre todas las vueltas;
+	// MejoresSectores indica en qué vuelta se marcó cada uno.
+	VueltaIdeal     float64        `json:"ideal_lap"`
+	MejoresSectores []TiempoSector `json:"mejores_sectores"`
+
+	Etapas []EtapaPipeline `json:"etapas,omitempty"` // solo con pipeline
+
+	Estadisticas Estadisticas `json:"estadisticas"` // de todos los tiempos de sector
+
+	BanderasRojas int `json:"banderas_rojas"` // vueltas cortadas por bandera roja
+
+	Circuito string `json:"circuito,omitempty"` // nombre del circuito elegido
+}
+
+// TiempoSector es el contenido estructurado de cada registro de sector MPI
+type TiempoSector struct {
+	Sector int     `json:"sector"`
+	Nombre string  `json:"nombre"`
+	Vuelta int     `json:"vuelta"`
+	Tiempo float64 `json:"tiempo"`
+
+	// MejorPersonal marca un sector más rápido que en todas las vueltas
+	// anteriores de la corrida (nunca en la primera vuelta)
+	MejorPersonal bool `json:"mejor_personal,omitempty"`
+
+	// DiferenciaMejor es el tiempo menos el mejor de ese sector en las
+	// vueltas anteriores: negativa si lo mejoró (0 en la primera vuelta)
+	DiferenciaMejor float64 `json:"diferencia_mejor,omitempty"`
+
+	// Color clasifica el tiempo como en el cronometraje de la F1: "morado" (el
+	// mejor de la sesión en ese sector), "verde" (mejor personal) o "amarillo"
+	// (solo en los registros de sector)
+	Color string `json:"color,omitempty"`
+}
+
+// ColumnaSector es el contenido estructurado del mensaje "grilla" de MPI
+// (vista grilla): un sector con su tiempo en cada vuelta corrida hasta ahora
+type ColumnaSector struct {
+	Sector  int       `json:"sector"`
+	Nombre  string    `json:"nombre"`
+	Tiempos []float64 `json:"tiempos"` // tiempos[v]: vuelta v+1 (0 si la cortó antes una bandera roja)
+
+	Ultimo TiempoSector `json:"ultimo"` // el tiempo que completó la columna, con su color
+}
+
+// AcumuladoMPI es el contenido estructurado del registro que cierra cada vuelta MPI
+type AcumuladoMPI struct {
+	Vuelta    int     `json:"vuelta"`
+	Acumulado float64 `json:"acumulado"` // tiempo de carrera hasta esta vuelta inclusive
+	// Diferencia es la vuelta menos la mejor de las anteriores: negativa si
+	// la mejoró (0 en la primera vuelta)
+	Diferencia float64 `json:"diferencia"`
+}
+
+// VueltaMPI es el tiempo total de una vuelta (suma de sus sectores)
+type VueltaMPI struct {
+	Numero     int     `json:"numero"`
+	Tiempo     float64 `json:"tiempo"`
+	Diferencia float64 `json:"diferencia"` // segundos respecto a la mejor vuelta de la sesión
+}
+
+// EtapaPipeline es el rendimiento de un sector cuando MPI corre en pipeline
+type EtapaPipeline struct {
+	Sector            int     `json:"sector"`
+	Nombre            string  `json:"nombre"`
+	Vueltas           int     `json:"vueltas"`     // vueltas que atravesaron la etapa
+	DuracionMs        float64 `json:"duracion_ms"` // desde que recibió la primera vuelta hasta que entregó la última
+	VueltasPorSegundo float64 `json:"vueltas_por_segundo"`
+}
+
+// Estadisticas describe un conjunto de tiempos generados (en segundos)
+type Estadisticas struct {
+	Cantidad int     `json:"cantidad"`
+	Minimo   float64 `json:"min"`
+	Maximo   float64 `json:"max"`
+	Media    float64 `json:"media"`
+	Desvio   float64 `json:"desvio"` // desvío estándar poblacional
+}
+
+// -------------------- OpenMP --------------------
+
+// ResultadoOpenMP guarda la mejor vuelta de un auto y el historial de sus vueltas
+type ResultadoOpenMP struct {
+	AutoID          int       `json:"auto_id"`
+	MejorVuelta     float64   `json:"mejor_vuelta"`     // 0 si abandonó sin completar una vuelta válida
+	CantidadVueltas int       `json:"cantidad_vueltas"` // vueltas completadas
+	Vueltas         []float64 `json:"vueltas"`          // tiempo de cada vuelta, en orden
+	PromedioVuelta  float64   `json:"promedio_vuelta"`  // promedio de Vueltas
+	ParadasBoxes    int       `json:"paradas_boxes"`
+
+	// DesgasteMotor son los segundos por vuelta que suma el desgaste del
+	// motor al final de la carrera; ProblemaMotor es la vuelta en la que el
+	// desgaste provocó un problema de motor (0 = ninguno)
+	DesgasteMotor float64 `json:"desgaste_motor"`
+	ProblemaMotor int     `json:"problema_motor,omitempty"`
+	BaseOffset    float64 `json:"base_offset"` // segundos que el auto suma a cada vuelta (base_offset de autos_config)
+	Stints        []Stint `json:"stints"`      // tramos entre paradas en boxes
+
+	// Abandono indica que el auto se retiró en VueltaAbandono, sin completarla
+	Abandono       bool `json:"abandono"`
+	VueltaAbandono int  `json:"vuelta_abandono,omitempty"`
+
+	Puntos int `json:"puntos"` // de campeonato: por la posición de llegada más el de la vuelta rápida
+}
+
+// Stint es un tramo de carrera entre paradas en boxes
+type Stint struct {
+	DesdeVuelta int     `json:"desde_vuelta"`
+	HastaVuelta int     `json:"hasta_vuelta"` // la vuelta de la parada cierra el stint
+	Mejor       float64 `json:"mejor"`        // sin la vuelta de la parada ni las neutralizadas (0 si no queda ninguna)
+	Promedio    float64 `json:"promedio"`     // de todas las vueltas del stint
+}
+
+// TiempoVueltaAuto es el contenido estructurado de cada registro de vuelta OpenMP
+type TiempoVueltaAuto struct {
+	Auto   int     `json:"auto"`
+	Vuelta int     `json:"vuelta"`
+	Tiempo float64 `json:"tiempo"`
+}
+
+// ResumenOpenMP es el contenido estructurado del mensaje "resumen" de OpenMP
+type ResumenOpenMP struct {
+	MejorPorAuto  []ResultadoOpenMP `json:"mejor_por_auto"`
+	MejorGeneral  *ResultadoOpenMP  `json:"mejor_general"` // nil si ningún auto completó vueltas
+	Clasificacion []ResultadoOpenMP `json:"clasificacion"` // ordenada de más rápido a más lento
+	Clima         string            `json:"clima"`
+
+	// Penalización por combustible en la primera y en la última vuelta (s)
+	CombustibleInicial float64 `json:"combustible_inicial"`
+	CombustibleFinal   float64 `json:"combustible_final"`
+
+	VueltasSafetyCar int `json:"vueltas_safety_car"` // vueltas neutralizadas (para todos los autos)
+
+	OrdenLlegada []PosicionCarrera `json:"orden_llegada"` // por tiempo total de carrera, abandonos al final
+
+	// TablaPuntos son los puntos de cada posición de llegada (el primero para
+	// el ganador); Puntos es el total de cada auto, de más a menos, y
+	// VueltaRapida el auto que sumó el punto de la vuelta rápida (0 = ninguno)
+	TablaPuntos  []int        `json:"tabla_puntos"`
+	Puntos       []PuntosAuto `json:"puntos"`
+	VueltaRapida int          `json:"vuelta_rapida,omitempty"`
+
+	Estadisticas Estadisticas `json:"estadisticas"` // de todas las vueltas de todos los autos
+}
+
+// PosicionCarrera es la posición de un auto según su tiempo acumulado
+type PosicionCarrera struct {
+	Posicion    int     `json:"posicion"`
+	Auto        int     `json:"auto"`
+	TiempoTotal float64 `json:"tiempo_total"` // de las vueltas completadas
+
+	Abandono       bool `json:"abandono,omitempty"`
+	VueltaAbandono int  `json:"vuelta_abandono,omitempty"`
+}
+
+// PuntosAuto es el total de puntos de campeonato de un auto en la carrera
+type PuntosAuto struct {
+	Auto         int  `json:"auto"`
+	Posicion     int  `json:"posicion"` // de llegada
+	Puntos       int  `json:"puntos"`
+	VueltaRapida bool `json:"vuelta_rapida,omitempty"` // incluye el punto de la vuelta rápida
+}
+
+// PosicionesVuelta es el contenido estructurado del mensaje "posiciones" que
+// se emite cuando todos los autos completan una vuelta
+type PosicionesVuelta struct {
+	Vuelta     int               `json:"vuelta"`
+	Posiciones []PosicionCarrera `json:"posiciones"` // Posiciones[i] es la del Auto i+1
+}
+
+// CambioPosicion es un auto cuya posición o mejor vuelta cambió en la vuelta
+type CambioPosicion struct {
+	Auto        int     `json:"auto"`
+	Posicion    int     `json:"posicion"`
+	MejorVuelta float64 `json:"mejor_vuelta"` // 0 si todavía no tiene una vuelta válida
+	TiempoTotal float64 `json:"tiempo_total"`
+}
+
+// DeltaPosiciones es el contenido estructurado del mensaje
+// "leaderboard_delta": solo los autos que cambiaron respecto de la vuelta
+// anterior, para no reenviar la tabla completa en cada vuelta
+type DeltaPosiciones struct {
+	Vuelta  int              `json:"vuelta"`
+	Cambios []CambioPosicion `json:"cambios"`
+}
+
+// ComparacionOpenMP es el contenido estructurado del resumen de comparar_openmp
+type ComparacionOpenMP struct {
+	A ResumenOpenMP `json:"a"`
+	B ResumenOpenMP `json:"b"`
+
+	// MasRapida es la configuración con la mejor vuelta más rápida: "a", "b" o "empate"
+	MasRapida string `json:"mas_rapida"`
+
+	// Diferencias de b respecto de a (negativas si b fue más rápida)
+	DiferenciaMejorVuelta float64 `json:"diferencia_mejor_vuelta"`
+	DiferenciaPromedio    float64 `json:"diferencia_promedio"` // promedio de todas las vueltas
+	DiferenciaGanador     float64 `json:"diferencia_ganador"`  // tiempo total del primero en llegar
+}
+
+// -------------------- Clasificación y sesión combinada --------------------
+
+// ResultadoClasificacion es la posición de un auto en la parrilla de salida
+type ResultadoClasificacion struct {
+	Posicion   int     `json:"posicion"`
+	AutoID     int     `json:"auto_id"`
+	Tiempo     float64 `json:"tiempo"`
+	Diferencia float64 `json:"diferencia"` // segundos respecto a la pole
+	Intervalo  string  `json:"intervalo"`  // Diferencia con formato "+X.XXX" ("" para la pole)
+	BaseOffset float64 `json:"base_offset"`
+}
+
+// ResumenClasificacion es el contenido estructurado del mensaje "resumen" de la clasificación
+type ResumenClasificacion struct {
+	Pole     ResultadoClasificacion   `json:"pole"`
+	Parrilla []ResultadoClasificacion `json:"parrilla"` // ordenada de la pole hacia atrás
+	Clima    string                   `json:"clima"`
+}
+
+// ResumenCombinado es el contenido estructurado del resumen de iniciar_sesion
+type ResumenCombinado struct {
+	Clasificacion ResumenClasificacion `json:"clasificacion"`
+	MPI           ResumenMPI           `json:"mpi"`
+}
+
+// -------------------- Anillo --------------------
+
+// ResumenAnillo es el contenido estructurado del mensaje "resumen" del anillo
+type ResumenAnillo struct {
+	Nodos   int `json:"nodos"`
+	Vueltas int `json:"vueltas"`
+	Saltos  int `json:"saltos"`
+
+	// Latencia de los saltos medida por cada nodo, de recibir a reenviar el
+	// token (incluye la pausa artificial)
+	LatenciaMediaMs float64 `json:"latencia_media_ms"`
+	LatenciaMinMs   float64 `json:"latencia_min_ms"`
+	LatenciaMaxMs   float64 `json:"latencia_max_ms"`
+
+	Variante string `json:"variante"`
+
+	// LatenciasVueltaMs es lo que tardó cada vuelta completa, medido por el
+	// nodo 0; solo en la variante coordinador
+	LatenciasVueltaMs []float64 `json:"latencias_vuelta_ms,omitempty"`
+}
+
+// LatenciaVuelta es el contenido estructurado del registro de cada vuelta
+// completa en la variante coordinador
+type LatenciaVuelta struct {
+	Vuelta     int     `json:"vuelta"`
+	LatenciaMs float64 `json:"latencia_ms"`
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// -------------------- Sesión WebSocket --------------------
+
+// ejecucion representa una simulación en curso que puede detenerse o pausarse
+type ejecucion struct {
+	runID     string
+	cancelar  context.CancelFunc
+	pausa     *compuerta
+	terminado chan struct{} // se cierra cuando el runner retorna
+}
+
+// enCurso indica si el runner todavía no retornó
+func (e *ejecucion) enCurso() bool {
+	select {
+	case <-e.terminado:
+		return false
+	default:
+		return true
+	}
+}
+
+// detener cancela la simulación y espera a que emita su finalizado
+func (e *ejecucion) detener() {
+	e.cancelar()
+	<-e.terminado
+}
+
+// sesion agrupa el estado de una conexión WebSocket: su canal de salida y las
+// simulaciones que lanzó, por tópico ("mpi" / "openmp" / "anillo" /
+// "reproduccion"). Solo la usa la goroutine que atiende la conexión, por lo
+// que no necesita mutex.
+type sesion struct {
+	ctx         context.Context // se cancela al apagar el servidor
+	enviar      chan MensajeWS
+	conexion    string // id de correlación de la conexión en los logs
+	version     string // versión de los mensajes negociada (ver negociarProtocolo)
+	ejecuciones map[string]*ejecucion
+	bitacora    *slog.Logger // logger con los datos de la conexión
+}
+
+func nuevaSesion(ctx context.Context, enviar chan MensajeWS, conexion, version string, bitacora *slog.Logger) *sesion {
+	return &sesion{ctx: ctx, enviar: enviar, conexion: conexion, version: version, ejecuciones: map[string]*ejecucion{}, bitacora: bitacora}
+}
+
+// maxSimulacionesPorConexion es cuántas simulaciones puede tener en curso una
+// conexión a la vez: alcanza para las tres columnas de la interfaz (MPI,
+// OpenMP y anillo) sin que un cliente pueda lanzar corridas sin límite.
+const maxSimulacionesPorConexion = 3
+
+// duracionMaxima es el tiempo máximo que puede correr una simulación, en
+// pausa o no; main lo toma del flag -maxduracion
+var duracionMaxima = 5 * time.Minute
+
+// conTiempoMaximo corre correr con un contexto que vence a las
+// duracionMaxima. Si vence, la simulación se detiene como con "detener" y
+// antes de su finalizado se avisa que se abortó por tiempo.
+func conTiempoMaximo(ctx context.Context, enviar chan MensajeWS, correr func(ctx context.Context, enviar chan MensajeWS)) {
+	ctx, cancelar := context.WithTimeout(ctx, duracionMaxima)
+	defer cancelar()
+	intermedio := make(chan MensajeWS)
+	hecho := make(chan struct{})
+	go func() {
+		defer close(hecho)
+		for msg := range intermedio {
+			if msg.Tipo == "finalizado" && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				enviar <- MensajeWS{Tipo: "registro", Topico: msg.Topico, Texto: fmt.Sprintf("Simulación abortada por tiempo máximo (%s)", duracionMaxima)}
+			}
+			enviar <- msg
+		}
+	}()
+	correr(ctx, intermedio)
+	close(intermedio)
+	<-hecho
+}
+
+// enCurso cuenta las simulaciones de la sesión que todavía no emitieron su
+// finalizado, sin contar la de excepto (que se reemplazaría al iniciar otra).
+func (s *sesion) enCurso(excepto string) int {
+	cantidad := 0
+	for topico, e := range s.ejecuciones {
+		if topico != excepto && e.enCurso() {
+			cantidad++
+		}
+	}
+	return cantidad
+}
+
+// detenerTodas cancela las simulaciones de la sesión y espera a que retornen
+func (s *sesion) detenerTodas() {
+	for _, e := range s.ejecuciones {
+		e.detener()
+	}
+}
+
+// iniciar lanza una simulación en el tópico indicado, deteniendo antes la que
+// estuviera corriendo en ese tópico. Con difundir=true la simulación escribe en
+// el hub y la ven todas las conexiones (incluida esta); si no, solo esta.
+// Si parametros no es nil, al terminar con un resumen la corrida se guarda
+// en el historial junto con la acción y la semilla de comando. Con lote_ms
+// los mensajes se envían agrupados (ver agruparEnLotes); la grabación y el
+// historial guardan los mensajes sueltos.
+func (s *sesion) iniciar(topico string, comando Comando, parametros any, correr func(ctx context.Context, pausa *compuerta, enviar chan MensajeWS)) {
+	if enCurso := s.enCurso(topico); enCurso >= maxSimulacionesPorConexion {
+		s.enviar <- mensajeError(topico, fmt.Errorf("límite de simulaciones alcanzado (%d en curso), esperar a que termine alguna", enCurso))
+		return
+	}
+	intervaloLote := milisegundos(valorO(comando.LoteMs, 0))
+	if intervaloLote < 0 {
+		s.enviar <- mensajeError(topico, errors.New("lote_ms debe ser >= 0"))
+		return
+	}
+	if anterior, ok := s.ejecuciones[topico]; ok && anterior.enCurso() {
+		s.enviar <- MensajeWS{Tipo: "registro", Topico: topico, RunID: anterior.runID, Texto: "Deteniendo la simulación anterior"}
+		anterior.detener()
+	}
+	ctx, cancelar := context.WithCancel(s.ctx)
+	e := &ejecucion{runID: nuevoRunID(), cancelar: cancelar, pausa: nuevaCompuerta(), terminado: make(chan struct{})}
+	s.ejecuciones[topico] = e
+	ctx, quitar := corridasActivas.agregar(ctx, EstadoCorrida{
+		RunID: e.runID, Conexion: s.conexion, Topico: topico, Accion: comando.Action, Parametros: parametros, Inicio: time.Now(),
+	}, cancelar)
+	difundir := comando.Difundir
+	destino := s.enviar
+	if difundir {
+		destino = espectadores.difusion
+	}
+	var lote chan MensajeWS
+	var loteListo <-chan struct{}
+	if intervaloLote > 0 {
+		lote, loteListo = agruparEnLotes(intervaloLote, destino)
+		destino = lote
+	}
+	detalle := comando.Action
+	if factor := comando.factorVelocidad(); factor != 1 {
+		detalle += fmt.Sprintf(", velocidad x%g", factor)
+	}
+	s.enviar <- MensajeWS{
+		Tipo:   "inicio",
+		Topico: topico,
+		RunID:  e.runID,
+		Texto:  fmt.Sprintf("Corrida %s preparada (%s)", e.runID, detalle),
+		Obj: InicioCorrida{
+			RunID: e.runID, Conexion: s.conexion, Version: s.version, Accion: comando.Action, Semilla: comando.Semilla,
+			FactorVelocidad: comando.factorVelocidad(), Parametros: parametros, HoraServidor: time.Now(),
+		},
+	}
+	s.enviar <- MensajeWS{Tipo: "registro", Topico: topico, RunID: e.runID, Texto: fmt.Sprintf("Simulación %s iniciada (run_id %s)", topico, e.runID)}
+	salida, listo := etiquetarCorrida(e.runID, destino)
+	bitacora := s.bitacora.With("topico", topico, "run_id", e.runID)
+	bitacora.Info("simulación iniciada", "difundir", difundir)
+	go func() {
+		defer close(e.terminado)
+		defer cancelar()
+		defer quitar()
+		defer medirCorrida(simulacionDeAccion(comando.Action))()
+		inicio := time.Now()
+		conTiempoMaximo(ctx, salida, func(ctx context.Context, enviar chan MensajeWS) {
+			correr(ctx, e.pausa, enviar)
+		})
+		close(salida)
+		g := <-listo
+		if lote != nil {
+			close(lote)
+			<-loteListo
+		}
+		bitacora.Info("simulación terminada", "duracion", time.Since(inicio).Round(time.Millisecond), "detenida", ctx.Err() != nil)
+		if parametros != nil {
+			if err := registrarEnHistorial(e.runID, comando.Action, comando.Semilla, parametros, inicio, g); err != nil {
+				bitacora.Warn("no se pudo guardar la corrida en el historial", "error", err)
+			}
+		}
+	}()
+}
+
+// Comando es un mensaje recibido del cliente por WebSocket. Los parámetros son
+// punteros para distinguir los ausentes (se usa el valor por defecto) de los
+// enviados en cero; un campo con el tipo equivocado hace fallar la decodificación.
+//
+// La etiqueta acciones indica qué comandos usan cada parámetro y desc lo
+// describe; con ellas se arma GET /api/comandos (ver describirComandos).
+type Comando struct {
+	Action   string   `json:"action"`
+	Topico   string   `json:"topico" acciones:"detener,pausar,reanudar" desc:"simulación a la que aplica (en detener, vacío = todas)"`
+	RunID    string   `json:"run_id" acciones:"reproducir" desc:"corrida a reproducir"`
+	Nombre   string   `json:"nombre" acciones:"escenario" desc:"escenario a correr"`
+	Difundir bool     `json:"difundir" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_anillo,reproducir,iniciar_sesion,escenario,comparar_openmp" desc:"enviar los mensajes a todos los espectadores"`
+	LoteMs   *float64 `json:"lote_ms" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_anillo,reproducir,iniciar_sesion,escenario,comparar_openmp" desc:"agrupa los mensajes de cada paso en un mensaje lote cada tantos milisegundos (0 = uno por evento)"`
+
+	Vueltas   *entero  `json:"vueltas" acciones:"iniciar_mpi,iniciar_openmp,iniciar_sesion" desc:"vueltas de la sesión"`
+	TiempoMin *float64 `json:"tiempo_min" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion" desc:"tiempo mínimo por sector (MPI) o por vuelta (OpenMP), en segundos"`
+	TiempoMax *float64 `json:"tiempo_max" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion" desc:"tiempo máximo por sector (MPI) o por vuelta (OpenMP), en segundos"`
+	DelayMs   *float64 `json:"delay_ms" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_sesion,iniciar_anillo" desc:"pausa entre pasos de la simulación, en milisegundos"`
+	Semilla   *int64   `json:"semilla" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_sesion,comparar_openmp" desc:"fija la secuencia de tiempos generados (sin semilla se usa la hora)"`
+	Clima     *string  `json:"clima" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_sesion" desc:"estado de la pista"`
+
+	// FactorVelocidad divide las pausas (las por defecto o delay_ms) y, en el
+	// anillo, también duracion_seg, así la corrida mantiene su ritmo relativo
+	FactorVelocidad *float64 `json:"factor_velocidad" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_sesion,iniciar_anillo,comparar_openmp,escenario" desc:"acelera (o frena) la simulación dividiendo sus pausas: 4 = cuatro veces más rápido"`
+
+	FormatoTiempo *string `json:"formato_tiempo" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_sesion" desc:"formato de los tiempos en los registros: s, ms o mm:ss.mmm"`
+	Distribucion  *string `json:"distribucion" acciones:"iniciar_mpi,iniciar_openmp,iniciar_clasificacion,iniciar_sesion" desc:"cómo se sortean los tiempos dentro de su rango: uniforme, normal o triangular"`
+
+	Sectores        *entero  `json:"sectores" acciones:"iniciar_mpi,iniciar_sesion" desc:"sectores de la pista"`
+	Degradacion     *float64 `json:"degradacion" acciones:"iniciar_mpi,iniciar_sesion" desc:"segundos que pierde cada sector por vuelta de uso del neumático"`
+	NombresSectores []string `json:"nombres_sectores" acciones:"iniciar_mpi,iniciar_sesion" desc:"nombre de cada sector, uno por sector"`
+	Circuito        *string  `json:"circuito" acciones:"iniciar_mpi,iniciar_sesion" desc:"circuito predefinido: fija los sectores y el rango de tiempos de cada uno"`
+	Pipeline        *bool    `json:"pipeline" acciones:"iniciar_mpi,iniciar_sesion" desc:"corre cada sector como una etapa de un pipeline entre vueltas"`
+	Vista           *string  `json:"vista" acciones:"iniciar_mpi,iniciar_sesion" desc:"filas (un registro por sector de cada vuelta) o grilla (la columna de cada sector con todas las vueltas)"`
+
+	BanderaRojaUmbral    *float64 `json:"bandera_roja_umbral" acciones:"iniciar_mpi,iniciar_sesion" desc:"tiempo de sector en segundos que provoca bandera roja y corta la vuelta (0 = sin bandera roja)"`
+	ContinuarTrasBandera *bool    `json:"continuar_tras_bandera" acciones:"iniciar_mpi,iniciar_sesion" desc:"tras una bandera roja sigue con la vuelta siguiente en lugar de terminar la sesión"`
+
+	Autos              *entero      `json:"autos" acciones:"iniciar_openmp,iniciar_clasificacion,iniciar_sesion" desc:"autos en pista"`
+	PitCada            *entero      `json:"pit_cada" acciones:"iniciar_openmp" desc:"vueltas entre paradas en boxes (0 = sin paradas)"`
+	PitTiempo          *float64     `json:"pit_tiempo" acciones:"iniciar_openmp" desc:"segundos que suma cada parada en boxes"`
+	CombustibleInicial *float64     `json:"combustible_inicial" acciones:"iniciar_openmp" desc:"penalización por combustible en la primera vuelta, en segundos"`
+	DesgasteMotor      *float64     `json:"desgaste_motor" acciones:"iniciar_openmp" desc:"segundos por vuelta que suma el desgaste del motor por cada vuelta anterior; al superar un umbral el auto tiene un problema de motor"`
+	SafetyCarProb      *float64     `json:"safety_car_prob" acciones:"iniciar_openmp" desc:"probabilidad de que una vuelta se corra detrás del safety car"`
+	ProbAbandono       *float64     `json:"prob_abandono" acciones:"iniciar_openmp" desc:"probabilidad de que un auto abandone en cada vuelta"`
+	Puntos             []int        `json:"puntos" acciones:"iniciar_openmp" desc:"puntos de campeonato de cada posición de llegada, desde el ganador; la vuelta rápida suma 1 si su auto llega entre los 10 primeros"`
+	Hilos              *entero      `json:"hilos" acciones:"iniciar_openmp" desc:"autos que corren a la vez (0 = uno por auto)"`
+	AutosConfig        []ConfigAuto `json:"autos_config" acciones:"iniciar_openmp,iniciar_clasificacion,iniciar_sesion" desc:"ajuste por auto, uno por auto: [{\"base_offset\": segundos}]"`
+
+	Nodos         *entero  `json:"nodos" acciones:"iniciar_anillo" desc:"nodos del anillo"`
+	VueltasAnillo *entero  `json:"vueltas_anillo" acciones:"iniciar_anillo" desc:"vueltas completas del token antes de terminar (0 = solo por tiempo)"`
+	DuracionSeg   *float64 `json:"duracion_seg" acciones:"iniciar_anillo" desc:"tiempo máximo que circula el token, en segundos"`
+	Variante      *string  `json:"variante" acciones:"iniciar_anillo" desc:"clasica (todos los nodos son goroutines) o coordinador (el runner es el nodo 0 y mide cada vuelta)"`
+
+	Velocidad *float64 `json:"velocidad" acciones:"reproducir" desc:"multiplica el ritmo original (2 = el doble de rápido)"`
+
+	// Las dos configuraciones de comparar_openmp, con los parámetros de
+	// iniciar_openmp; la semilla es la del comando, compartida por las dos
+	A *Comando `json:"a" acciones:"comparar_openmp" desc:"parámetros de la configuración a, como en iniciar_openmp"`
+	B *Comando `json:"b" acciones:"comparar_openmp" desc:"parámetros de la configuración b, como en iniciar_openmp"`
+}
+
+// entero es un parámetro de cantidad (sectores, vueltas, autos...). Se
+// decodifica como número para aceptar valores como 5.0; validarEnteros rechaza
+// los que tienen parte decimal en vez de truncarlos.
+type entero float64
+
+// maxEntero es el mayor entero que un float64 representa sin perder precisión
+const maxEntero = 1 << 53
+
+// nombreTipo describe el tipo esperado de un campo en los mensajes de error
+func nombreTipo(t reflect.Type) string {
+	if t == reflect.TypeOf(entero(0)) {
+		return "entero"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "entero"
+	case reflect.Float64:
+		return "número"
+	case reflect.String:
+		return "texto"
+	case reflect.Bool:
+		return "booleano"
+	case reflect.Slice:
+		return "lista"
+	case reflect.Struct:
+		return "objeto"
+	}
+	return t.String()
+}
+
+// validarEnteros comprueba que los parámetros de cantidad no tengan decimales
+func (c Comando) validarEnteros() error {
+	campos := []struct {
+		nombre string
+		valor  *entero
+	}{
+		{"sectores", c.Sectores},
+		{"vueltas", c.Vueltas},
+		{"autos", c.Autos},
+		{"pit_cada", c.PitCada},
+		{"hilos", c.Hilos},
+		{"nodos", c.Nodos},
+		{"vueltas_anillo", c.VueltasAnillo},
+	}
+	for _, campo := range campos {
+		if campo.valor == nil {
+			continue
+		}
+		if v := float64(*campo.valor); v != math.Trunc(v) || math.Abs(v) > maxEntero {
+			return fmt.Errorf("%s debe ser entero", campo.nombre)
+		}
+	}
+	for i, sub := range []*Comando{c.A, c.B} {
+		if sub == nil {
+			continue
+		}
+		if err := sub.validarEnteros(); err != nil {
+			return fmt.Errorf("%s: %w", []string{"a", "b"}[i], err)
+		}
+	}
+	return nil
+}
+
+// decodificarComando interpreta datos como un Comando. El error describe el
+// campo con tipo equivocado para poder informarlo al cliente.
+func decodificarComando(datos []byte) (Comando, error) {
+	var c Comando
+	err := json.Unmarshal(datos, &c)
+	var errTipo *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &errTipo):
+		return c, fmt.Errorf("el campo %s debe ser de tipo %s", errTipo.Field, nombreTipo(errTipo.Type))
+	case err != nil:
+		return c, fmt.Errorf("comando JSON inválido: %v", err)
+	}
+	if err := c.validarEnteros(); err != nil {
+		return c, err
+	}
+	return c, c.validarFactorVelocidad()
+}
+
+// Límites de factor_velocidad
+const (
+	factorVelocidadMin = 0.1
+	factorVelocidadMax = 100.0
+)
+
+// validarFactorVelocidad comprueba que factor_velocidad esté entre
+// factorVelocidadMin y factorVelocidadMax
+func (c Comando) validarFactorVelocidad() error {
+	if c.FactorVelocidad == nil {
+		return nil
+	}
+	if f := *c.FactorVelocidad; !(f >= factorVelocidadMin && f <= factorVelocidadMax) {
+		return fmt.Errorf("factor_velocidad debe estar entre %g y %g", factorVelocidadMin, factorVelocidadMax)
+	}
+	return nil
+}
+
+// factorVelocidad devuelve el factor_velocidad del comando (1 si no lo envió)
+func (c Comando) factorVelocidad() float64 {
+	return valorO(c.FactorVelocidad, 1)
+}
+
+// acelerar divide la duración d por el factor_velocidad del comando
+func (c Comando) acelerar(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / c.factorVelocidad())
+}
+
+// fijarSemilla elige la semilla de las simulaciones que usan una y no la
+// indicaron, así queda guardada en el historial y la corrida se puede repetir
+func (c *Comando) fijarSemilla() {
+	usaSemilla := strings.HasPrefix(c.Action, "iniciar_") && c.Action != "iniciar_anillo" || c.Action == "comparar_openmp"
+	if c.Semilla != nil || !usaSemilla {
+		return
+	}
+	semilla := resolverSemilla(nil)
+	c.Semilla = &semilla
+}
+
+// milisegundos convierte un retardo en ms del cliente a time.Duration
+func milisegundos(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// valorO devuelve el parámetro enviado por el cliente o defecto si no lo envió
+func valorO[T any](v *T, defecto T) T {
+	if v == nil {
+		return defecto
+	}
+	return *v
+}
+
+// enteroO es valorO para los parámetros de cantidad; validarEnteros ya
+// comprobó que no tengan decimales
+func enteroO(v *entero, defecto int) int {
+	if v == nil {
+		return defecto
+	}
+	return int(*v)
+}
+
+// parametrosMPI combina los parámetros de c con los valores por defecto
+func (c Comando) parametrosMPI() ParametrosMPI {
+	p := parametrosMPIPorDefecto()
+	p.Sectores = enteroO(c.Sectores, p.Sectores)
+	p.Vueltas = enteroO(c.Vueltas, p.Vueltas)
+	p.TiempoMin = valorO(c.TiempoMin, p.TiempoMin)
+	p.TiempoMax = valorO(c.TiempoMax, p.TiempoMax)
+	p.Degradacion = valorO(c.Degradacion, p.Degradacion)
+	if c.DelayMs != nil {
+		p.Retardo = milisegundos(*c.DelayMs)
+	}
+	p.Retardo = c.acelerar(p.Retardo)
+	p.Clima = valorO(c.Clima, p.Clima)
+	p.Semilla = c.Semilla
+	p.NombresSectores = c.NombresSectores
+	p.Pipeline = valorO(c.Pipeline, p.Pipeline)
+	p.Vista = valorO(c.Vista, p.Vista)
+	p.BanderaRojaUmbral = valorO(c.BanderaRojaUmbral, p.BanderaRojaUmbral)
+	p.ContinuarTrasBandera = valorO(c.ContinuarTrasBandera, p.ContinuarTrasBandera)
+	p.FormatoTiempo = valorO(c.FormatoTiempo, p.FormatoTiempo)
+	p.Distribucion = valorO(c.Distribucion, p.Distribucion)
+	p.Circuito = valorO(c.Circuito, p.Circuito)
+	return p
+}
+
+// parametrosOpenMP combina los parámetros de c con los valores por defecto
+func (c Comando) parametrosOpenMP() ParametrosOpenMP {
+	p := parametrosOpenMPPorDefecto()
+	p.Autos = enteroO(c.Autos, p.Autos)
+	p.Vueltas = enteroO(c.Vueltas, p.Vueltas)
+	p.TiempoMin = valorO(c.TiempoMin, p.TiempoMin)
+	p.TiempoMax = valorO(c.TiempoMax, p.TiempoMax)
+	p.PitCada = enteroO(c.PitCada, p.PitCada)
+	p.PitTiempo = valorO(c.PitTiempo, p.PitTiempo)
+	p.CombustibleInicial = valorO(c.CombustibleInicial, p.CombustibleInicial)
+	p.SafetyCarProb = valorO(c.SafetyCarProb, p.SafetyCarProb)
+	p.DesgasteMotor = valorO(c.DesgasteMotor, p.DesgasteMotor)
+	p.ProbAbandono = valorO(c.ProbAbandono, p.ProbAbandono)
+	p.Hilos = enteroO(c.Hilos, p.Hilos)
+	if c.Puntos != nil {
+		p.Puntos = c.Puntos
+	}
+	p.AutosConfig = c.AutosConfig
+	if c.DelayMs != nil {
+		p.Retardo = milisegundos(*c.DelayMs)
+	}
+	p.Retardo = c.acelerar(p.Retardo)
+	p.Clima = valorO(c.Clima, p.Clima)
+	p.Semilla = c.Semilla
+	p.FormatoTiempo = valorO(c.FormatoTiempo, p.FormatoTiempo)
+	p.Distribucion = valorO(c.Distribucion, p.Distribucion)
+	return p
+}
+
+// parametrosAnillo combina los parámetros de c con los valores por defecto
+func (c Comando) parametrosAnillo() ParametrosAnillo {
+	p := ParametrosAnillo{Nodos: 5, Vueltas: 3, Duracion: time.Minute, Variante: varianteClasica, Retardo: retardoSalto}
+	p.Nodos = enteroO(c.Nodos, p.Nodos)
+	p.Vueltas = enteroO(c.VueltasAnillo, p.Vueltas)
+	p.Variante = valorO(c.Variante, p.Variante)
+	if c.DuracionSeg != nil {
+		p.Duracion = time.Duration(*c.DuracionSeg * float64(time.Second))
+	}
+	if c.DelayMs != nil {
+		p.Retardo = milisegundos(*c.DelayMs)
+	}
+	p.Retardo, p.Duracion = c.acelerar(p.Retardo), c.acelerar(p.Duracion)
+	return p
+}
+
+// procesarComando decodifica y atiende un comando recibido del cliente. Los
+// errores de decodificación y de validación se informan por enviar; las
+// simulaciones corren en segundo plano.
+func (s *sesion) procesarComando(datos []byte) {
+	comando, err := decodificarComando(datos)
+	if err != nil {
+		s.bitacora.Warn("comando inválido", "error", err)
+		s.enviar <- mensajeError("", err)
+		return
+	}
+	s.bitacora.Debug("comando recibido", "action", comando.Action)
+	s.atender(comando)
+}
+
+// atender ejecuta un comando ya decodificado
+func (s *sesion) atender(comando Comando) {
+	comando.fijarSemilla()
+	switch comando.Action {
+	case "iniciar_mpi":
+		p := comando.parametrosMPI().conCircuito()
+		s.iniciar("mpi", comando, p, func(ctx context.Context, pausa *compuerta, enviar chan MensajeWS) {
+			correrMPI(ctx, p, pausa, enviar)
+		})
+	case "iniciar_openmp":
+		p := comando.parametrosOpenMP()
+		s.iniciar("openmp", comando, p, func(ctx context.Context, pausa *compuerta, enviar chan MensajeWS) {
+			correrOpenMP(ctx, p, pausa, enviar)
+		})
+	case "iniciar_clasificacion":
+		// Comparte tópico con la carrera: se muestra en la columna OpenMP
+		p := comando.parametrosOpenMP()
+		s.iniciar("openmp", comando, p, func(ctx context.Context, pausa *compuerta, enviar chan MensajeWS) {
+			correrClasificacion(ctx, p, pausa, enviar)
+		})
+	case "iniciar_sesion":
+		// Los rangos de tiempo de cada fase son distintos (vuelta vs.
+		// sector): un único tiempo_min/tiempo_max no sirve para las dos
+		if comando.TiempoMin != nil || comando.TiempoMax != nil {
+			s.enviar <- mensajeError("", errors.New("iniciar_sesion no acepta tiempo_min ni tiempo_max: cada fase usa su propio rango de tiempos"))
+			return
+		}
+		po, pm := comando.parametrosOpenMP(), comando.parametrosMPI().conCircuito()
+		s.iniciar("sesion", comando, map[string]any{"clasificacion": po, "mpi": pm}, func(ctx context.Context, pausa *compuerta, enviar chan MensajeWS) {
+			correrCombinada(ctx, po, pm, pausa, enviar)
+		})
+	case "comparar_openmp":
+		if comando.A == nil || comando.B == nil {
+			s.enviar <- mensajeError("", errors.New("comparar_openmp necesita las configuraciones a y b"))
+			return
+		}
+		comando.A.FactorVelocidad, comando.B.FactorVelocidad = comando.FactorVelocidad, comando.FactorVelocidad
+		pa, pb := comando.A.parametrosOpenMP(), comando.B.parametrosOpenMP()
+		pa.Semilla, pb.Semilla = comando.Semilla, comando.Semilla
+		pa.Topico, pb.Topico = "openmp_a", "openmp_b"
+		s.iniciar("comparar", comando, map[string]any{"a": pa, "b": pb}, func(ctx context.Context, pausa *compuerta, enviar chan MensajeWS) {
+			correrComparacion(ctx, pa, pb, pausa, enviar)
+		})
+	case "iniciar_anillo":
+		p := comando.parametrosAnillo()
+		s.iniciar("anillo", comando, p, func(ctx context.Context, _ *compuerta, enviar chan MensajeWS) {
+			correrAnillo(ctx, p, enviar)
+		})
+	case "escenario":
+		e, c, err := comandoEscenario(comando.Nombre)
+		if err != nil {
+			s.enviar <- mensajeError("", err)
+			return
+		}
+		s.enviar <- MensajeWS{Tipo: "registro", Texto: fmt.Sprintf("Escenario %s: %s", comando.Nombre, e.Descripcion)}
+		c.Difundir, c.LoteMs, c.FactorVelocidad = comando.Difundir, comando.LoteMs, comando.FactorVelocidad
+		s.atender(c)
+	case "reproducir":
+		g, ok := obtenerGrabacion(comando.RunID)
+		if !ok {
+			s.enviar <- mensajeError("", fmt.Errorf("no hay una corrida guardada con run_id %q", comando.RunID))
+			return
+		}
+		velocidad := velocidadPorDefecto
+		if comando.Velocidad != nil {
+			velocidad = *comando.Velocidad
+		}
+		if velocidad <= 0 {
+			s.enviar <- mensajeError("", errors.New("velocidad debe ser > 0"))
+			return
+		}
+		comando.FactorVelocidad = nil // la reproducción usa velocidad
+		s.iniciar("reproduccion", comando, nil, func(ctx context.Context, _ *compuerta, enviar chan MensajeWS) {
+			reproducir(ctx, comando.RunID, g, velocidad, enviar)
+		})
+	case "reiniciar":
+		// Solo se guarda lo de corridas ya terminadas, así que borrarlo no
+		// afecta a las que están en curso (guardarán su resultado al terminar).
+		borrarUltimoOpenMP()
+		borradas := borrarGrabaciones()
+		s.enviar <- MensajeWS{Tipo: "registro", Texto: fmt.Sprintf("Estado reiniciado: se borró el último resultado OpenMP y %d grabaciones", borradas)}
+	case "detener":
+		switch topico := comando.Topico; topico {
+		case "":
+			for _, e := range s.ejecuciones {
+				e.detener()
+			}
+		case "mpi", "openmp", "anillo", "reproduccion", "sesion", "comparar":
+			if e, ok := s.ejecuciones[topico]; ok {
+				e.detener()
+			}
+		default:
+			s.enviar <- MensajeWS{Tipo: "registro", Texto: fmt.Sprintf("Tópico no reconocido: %q", topico)}
+		}
+	case "estado":
+		estados := corridasActivas.instantanea()
+		texto := fmt.Sprintf("%d simulaciones en curso", len(estados))
+		for _, e := range estados {
+			texto += fmt.Sprintf("; %s %s (run_id %s): vuelta %d", e.Topico, e.Accion, e.RunID, e.Progreso.Vuelta)
+			if e.Progreso.Sector > 0 {
+				texto += fmt.Sprintf(", sector %d", e.Progreso.Sector)
+			}
+		}
+		s.enviar <- MensajeWS{Tipo: "estado", Texto: texto, Obj: estados}
+	case "pausar", "reanudar":
+		topico := comando.Topico
+		if topico != "mpi" && topico != "openmp" && topico != "sesion" && topico != "comparar" {
+			s.enviar <- MensajeWS{Tipo: "registro", Texto: fmt.Sprintf("Solo se puede pausar/reanudar mpi, openmp, sesion o comparar, no %q", topico)}
+			return
+		}
+		e, ok := s.ejecuciones[topico]
+		if !ok || !e.enCurso() {
+			s.enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: "No hay simulación en curso"}
+			return
+		}
+		if comando.Action == "pausar" {
+			e.pausa.pausar()
+			s.enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: "Simulación en pausa"}
+		} else {
+			e.pausa.reanudar()
+			s.enviar <- MensajeWS{Tipo: "registro", Topico: topico, Texto: "Simulación reanudada"}
+		}
+	default:
+		s.bitacora.Warn("comando no reconocido", "action", comando.Action)
+		s.enviar <- MensajeWS{Tipo: "registro", Texto: fmt.Sprintf("Comando no reconocido: %q", comando.Action)}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sesionPrueba arma una sesión sin socket; sus mensajes quedan en el canal devuelto
+func sesionPrueba(t *testing.T) (*sesion, chan MensajeWS) {
+	t.Helper()
+	enviar := make(chan MensajeWS, 100)
+	s := nuevaSesion(context.Background(), enviar, "prueba", protocoloV1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(s.detenerTodas)
+	return s, enviar
+}
+
+// esperarFinalizado lee de enviar hasta el finalizado del tópico indicado
+func esperarFinalizado(t *testing.T, enviar chan MensajeWS, topico string) []MensajeWS {
+	t.Helper()
+	var mensajes []MensajeWS
+	plazo := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-enviar:
+			mensajes = append(mensajes, msg)
+			if msg.Tipo == "finalizado" && msg.Topico == topico {
+				return mensajes
+			}
+		case <-plazo:
+			t.Fatalf("no llegó el finalizado de %s; mensajes: %+v", topico, mensajes)
+		}
+	}
+}
+
+// comprobarErrorYFinalizado verifica que la corrida informó un error de su
+// tópico, sin resumen, y terminó con su finalizado
+func comprobarErrorYFinalizado(t *testing.T, mensajes []MensajeWS, topico string) {
+	t.Helper()
+	errores := 0
+	for _, msg := range mensajes {
+		switch msg.Tipo {
+		case "error":
+			if msg.Topico == topico {
+				errores++
+			}
+		case "resumen":
+			t.Errorf("no debería haber resumen: %s", msg.Texto)
+		}
+	}
+	if errores != 1 {
+		t.Errorf("%d errores de %s, se esperaba 1; mensajes: %+v", errores, topico, mensajes)
+	}
+	if ultimo := mensajes[len(mensajes)-1]; ultimo.Tipo != "finalizado" {
+		t.Errorf("el último mensaje es %q, se esperaba finalizado", ultimo.Tipo)
+	}
+}
+
+func TestSesionSectoresInvalidos(t *testing.T) {
+	s, enviar := sesionPrueba(t)
+	s.procesarComando([]byte(`{"action":"iniciar_mpi","sectores":0,"vueltas":2}`))
+	comprobarErrorYFinalizado(t, esperarFinalizado(t, enviar, "mpi"), "mpi")
+}
+
+func TestValidarEnteros(t *testing.T) {
+	c, err := decodificarComando([]byte(`{"action":"iniciar_mpi","sectores":5.0,"vueltas":2}`))
+	if err != nil {
+		t.Fatalf("5.0 rechazado: %v", err)
+	}
+	if p := c.parametrosMPI(); p.Sectores != 5 {
+		t.Errorf("5.0 se tomó como %d sectores", p.Sectores)
+	}
+
+	for _, campo := range []string{"sectores", "vueltas", "autos", "hilos", "nodos"} {
+		datos := fmt.Sprintf(`{"action":"iniciar_mpi",%q:5.7}`, campo)
+		if _, err := decodificarComando([]byte(datos)); err == nil || err.Error() != campo+" debe ser entero" {
+			t.Errorf("%s 5.7: error %v, se esperaba %q", campo, err, campo+" debe ser entero")
+		}
+	}
+	if _, err := decodificarComando([]byte(`{"action":"comparar_openmp","a":{"autos":2.5}}`)); err == nil {
+		t.Errorf("se aceptó autos 2.5 dentro de a")
+	}
+
+	// Los negativos son enteros: los rechaza la validación de la simulación
+	c, err = decodificarComando([]byte(`{"action":"iniciar_mpi","sectores":-3}`))
+	if err != nil {
+		t.Fatalf("-3 rechazado al decodificar: %v", err)
+	}
+	if err := c.parametrosMPI().validar(); err == nil {
+		t.Errorf("se aceptaron -3 sectores")
+	}
+	s, enviar := sesionPrueba(t)
+	s.procesarComando([]byte(`{"action":"iniciar_mpi","sectores":3,"vueltas":-2}`))
+	comprobarErrorYFinalizado(t, esperarFinalizado(t, enviar, "mpi"), "mpi")
+}
+
+func TestConTiempoMaximoAbortaLaCorrida(t *testing.T) {
+	anterior := duracionMaxima
+	duracionMaxima = 20 * time.Millisecond
+	t.Cleanup(func() { duracionMaxima = anterior })
+
+	p := parametrosMPIPrueba(5, 1000)
+	p.Retardo = time.Millisecond
+	mensajes := correrHastaElFinal(func(ctx context.Context, enviar chan MensajeWS) {
+		conTiempoMaximo(ctx, enviar, func(ctx context.Context, enviar chan MensajeWS) {
+			correrMPI(ctx, p, nil, enviar)
+		})
+	})
+	if len(mensajes) < 2 {
+		t.Fatalf("solo llegaron %d mensajes", len(mensajes))
+	}
+	aviso, ultimo := mensajes[len(mensajes)-2], mensajes[len(mensajes)-1]
+	if aviso.Tipo != "registro" || aviso.Topico != "mpi" || !strings.Contains(aviso.Texto, "abortada por tiempo máximo") {
+		t.Errorf("antes del finalizado llegó %+v, se esperaba el aviso de tiempo máximo", aviso)
+	}
+	if ultimo.Tipo != "finalizado" {
+		t.Errorf("el último mensaje es %q, se esperaba finalizado", ultimo.Tipo)
+	}
+	for _, msg := range mensajes {
+		if msg.Tipo == "resumen" {
+			t.Errorf("una corrida abortada no debería tener resumen")
+		}
+	}
+}
+
+func TestDecodificarComandoParametros(t *testing.T) {
+	// Ausentes: quedan en nil y se usan los valores por defecto
+	c, err := decodificarComando([]byte(`{"action":"iniciar_openmp"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Autos != nil || c.Vueltas != nil || c.Clima != nil {
+		t.Errorf("parámetros ausentes decodificados: %+v", c)
+	}
+	if p, defecto := c.parametrosOpenMP(), parametrosOpenMPPorDefecto(); p.Autos != defecto.Autos || p.Vueltas != defecto.Vueltas || p.Clima != defecto.Clima {
+		t.Errorf("sin parámetros: %d autos, %d vueltas, clima %q; se esperaban los valores por defecto", p.Autos, p.Vueltas, p.Clima)
+	}
+
+	// Presentes, incluso en cero: se respetan
+	c, err = decodificarComando([]byte(`{"action":"iniciar_openmp","autos":4,"vueltas":0,"clima":"lluvia","pit_tiempo":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.parametrosOpenMP(); p.Autos != 4 || p.Vueltas != 0 || p.Clima != "lluvia" || p.PitTiempo != 0 {
+		t.Errorf("parámetros enviados: %d autos, %d vueltas, clima %q, pit_tiempo %g", p.Autos, p.Vueltas, p.Clima, p.PitTiempo)
+	}
+
+	// Con el tipo equivocado: el error nombra el campo y el tipo esperado
+	casos := map[string]string{
+		`{"action":"iniciar_mpi","sectores":"5"}`:   "el campo sectores debe ser de tipo entero",
+		`{"action":"iniciar_mpi","clima":3}`:        "el campo clima debe ser de tipo texto",
+		`{"action":"iniciar_mpi","pipeline":"si"}`:  "el campo pipeline debe ser de tipo booleano",
+		`{"action":"iniciar_mpi","tiempo_min":"1"}`: "el campo tiempo_min debe ser de tipo número",
+	}
+	for datos, esperado := range casos {
+		if _, err := decodificarComando([]byte(datos)); err == nil || err.Error() != esperado {
+			t.Errorf("%s: error %v, se esperaba %q", datos, err, esperado)
+		}
+	}
+	if _, err := decodificarComando([]byte(`{"action":`)); err == nil {
+		t.Errorf("se aceptó un JSON incompleto")
+	}
+}
+
+func TestIniciarSesionRechazaRangosDeTiempo(t *testing.T) {
+	s, enviar := sesionPrueba(t)
+	for _, campo := range []string{"tiempo_min", "tiempo_max"} {
+		s.procesarComando([]byte(fmt.Sprintf(`{"action":"iniciar_sesion","autos":2,"vueltas":1,%q:20}`, campo)))
+		select {
+		case msg := <-enviar:
+			if msg.Tipo != "error" || !strings.Contains(msg.Error, "tiempo_min ni tiempo_max") {
+				t.Errorf("%s: llegó %+v, se esperaba el error", campo, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: no llegó ningún mensaje", campo)
+		}
+		if len(s.ejecuciones) != 0 {
+			t.Errorf("%s: se inició la sesión igual", campo)
+		}
+	}
+}